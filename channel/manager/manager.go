@@ -38,6 +38,12 @@ type Manager struct {
 	// Default: middleware.DefaultManager.Driver
 	WrapDriver func(driver.Driver) driver.Driver
 
+	// Outermost is the middleware always applied outside of WrapDriver,
+	// such as the recover middleware, so that it covers all the others.
+	//
+	// Default: nil
+	Outermost middleware.Middleware
+
 	// GetChannelName is used to get the channel name by the message
 	// when the message name is empty.
 	//
@@ -70,9 +76,15 @@ func (m *Manager) getChannels() map[string]channel.Channel {
 
 func (m *Manager) wrap(d driver.Driver) driver.Driver {
 	if m.WrapDriver != nil {
-		return m.WrapDriver(d)
+		d = m.WrapDriver(d)
+	} else {
+		d = middleware.DefaultManager.Driver(d)
+	}
+
+	if m.Outermost != nil {
+		d = m.Outermost.Driver(d)
 	}
-	return middleware.DefaultManager.Driver(d)
+	return d
 }
 
 func (m *Manager) getChannelName(ctx context.Context, msg driver.Message) (string, error) {
@@ -0,0 +1,119 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/tools/retryafter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// HTTPError is returned by the drivers based on the http api of the email provider,
+// such as "sendgrid", "mailgun" and "ses", when the provider responds with a failure.
+type HTTPError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+
+	retryAfter time.Duration
+}
+
+// Error implements the interface error.
+func (e HTTPError) Error() string {
+	return fmt.Sprintf("%s: status=%d, body=%s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the error is temporary,
+// that's, the status code is 429 or 5xx.
+func (e HTTPError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// RetryAfter returns the delay to retry, which is parsed from
+// the response header Retry-After. Return 0 if not set.
+func (e HTTPError) RetryAfter() time.Duration { return e.retryAfter }
+
+// apiClient is the common http client of the email providers.
+type apiClient struct {
+	do       func(*http.Request) (*http.Response, error)
+	provider string
+	endpoint string
+	timeout  time.Duration
+}
+
+func newAPIClient(config map[string]any, provider, endpoint string) (c apiClient, err error) {
+	c = apiClient{do: http.DefaultClient.Do, provider: provider}
+
+	if c.endpoint, err = getString(config, "endpoint"); err != nil {
+		return
+	} else if c.endpoint == "" {
+		c.endpoint = endpoint
+	}
+	c.endpoint = strings.TrimRight(c.endpoint, "/")
+
+	if c.timeout, err = getDuration(config, "timeout", 10*time.Second); err != nil {
+		return
+	} else if c.timeout <= 0 {
+		err = fmt.Errorf("invalid timeout '%s'", c.timeout)
+	}
+
+	return
+}
+
+// Do sends the request and returns the response body if the status code is 2xx.
+//
+// The request is sent with the timeout, and setup is used to set up the request,
+// such as the headers and the signature, if not nil.
+func (c apiClient) Do(ctx context.Context, req *http.Request, setup func(*http.Request) error) (
+	body []byte, header http.Header, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if setup != nil {
+		if err = setup(req); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", c.provider, err)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: fail to send the request: %w", c.provider, err)
+	}
+	defer resp.Body.Close()
+
+	if body, err = io.ReadAll(io.LimitReader(resp.Body, 64*1024)); err != nil {
+		return nil, nil, fmt.Errorf("%s: fail to read the response: %w", c.provider, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, HTTPError{
+			Provider:   c.provider,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+			retryAfter: retryafter.Parse(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return body, resp.Header, nil
+}
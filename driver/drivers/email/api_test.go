@@ -0,0 +1,115 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// apiRequest is the request received by the fake api server.
+type apiRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// newAPIServer returns a fake api server, which responds with the status code,
+// the headers and the body, and records the last request.
+func newAPIServer(t *testing.T, status int, header map[string]string, body string) (endpoint string, last *apiRequest) {
+	last = new(apiRequest)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		*last = apiRequest{Method: r.Method, Path: r.URL.EscapedPath(), Header: r.Header, Body: data}
+
+		for key, value := range header {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, last
+}
+
+// testAPIError checks that the driver maps the failure response to HTTPError.
+func testAPIError(t *testing.T, build func(endpoint string) (driver.Driver, error)) {
+	for _, c := range []struct {
+		status     int
+		retryafter string
+		temporary  bool
+		delay      time.Duration
+	}{
+		{status: 400, temporary: false},
+		{status: 429, retryafter: "3", temporary: true, delay: 3 * time.Second},
+		{status: 503, temporary: true},
+	} {
+		endpoint, _ := newAPIServer(t, c.status, map[string]string{"Retry-After": c.retryafter}, " failure\n")
+		d, err := build(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var herr HTTPError
+		err = d.Send(context.Background(), driver.NewMessage("", "", "to@example.com", Message{Subject: "subject", Content: "content"}, nil))
+		switch {
+		case !errors.As(err, &herr):
+			t.Errorf("%d: expect an HTTPError, but got %v", c.status, err)
+		case herr.StatusCode != c.status || herr.Body != "failure" || herr.Provider != d.Type():
+			t.Errorf("%d: unexpected error %+v", c.status, herr)
+		case herr.Temporary() != c.temporary:
+			t.Errorf("%d: expect temporary %v, but got %v", c.status, c.temporary, herr.Temporary())
+		case herr.RetryAfter() != c.delay:
+			t.Errorf("%d: expect retry after %s, but got %s", c.status, c.delay, herr.RetryAfter())
+		}
+	}
+}
+
+func TestAPITracePropagation(t *testing.T) {
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	endpoint, req := newAPIServer(t, 202, nil, "")
+	d, err := NewSendGrid("sendgrid", map[string]any{"apikey": "key", "from": "sender@example.com", "endpoint": endpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	c := trace.ContextWithSpanContext(context.Background(), sc)
+	if err := d.Send(c, driver.NewMessage("", "", "to@example.com", Message{Subject: "subject", Content: "content"}, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"
+	if traceparent := req.Header.Get("Traceparent"); traceparent != expect {
+		t.Errorf("expect traceparent '%s', but got '%s'", expect, traceparent)
+	}
+}
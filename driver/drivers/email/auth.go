@@ -0,0 +1,381 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-toolkit/jsonx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// newAuth builds the smtp authentication from the config.
+//
+// Return nil if the authentication is disabled.
+func newAuth(config map[string]any, host, tlsmode string) (auth smtp.Auth, err error) {
+	username, err := getString(config, "username")
+	if err != nil {
+		return
+	}
+
+	password, err := getString(config, "password")
+	if err != nil {
+		return
+	}
+
+	mechanism, err := getString(config, "auth")
+	if err != nil {
+		return
+	} else if mechanism == "" {
+		if username == "" {
+			mechanism = "none"
+		} else {
+			mechanism = "plain"
+		}
+	}
+
+	insecure, err := getBool(config, "allowinsecureauth", false)
+	if err != nil {
+		return
+	}
+
+	// Refuse to send the credential in cleartext, like smtp.PlainAuth.
+	mechanism = strings.ToLower(mechanism)
+	switch mechanism {
+	case "plain", "login", "xoauth2":
+		if tlsmode == tlsNone && !insecure && !isLocalhost(host) {
+			return nil, fmt.Errorf("auth '%s' over the unencrypted connection is refused, "+
+				"use tls or set allowinsecureauth", mechanism)
+		}
+	}
+
+	switch mechanism {
+	case "none":
+		return nil, nil
+
+	case "xoauth2":
+		if username == "" {
+			return nil, errors.New("username is missing or invalid")
+		}
+		return newXOAuth2Auth(config, username, insecure)
+
+	case "plain", "login", "crammd5", "cram-md5":
+		if username == "" {
+			return nil, errors.New("username is missing or invalid")
+		}
+		if password == "" {
+			return nil, errors.New("password is missing or invalid")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported auth '%s'", mechanism)
+	}
+
+	switch mechanism {
+	case "login":
+		auth = &loginAuth{username: username, password: password, insecure: insecure}
+
+	case "crammd5", "cram-md5":
+		auth = smtp.CRAMMD5Auth(username, password)
+
+	default:
+		if insecure {
+			auth = newPlainAuth("", username, password, host)
+		} else {
+			auth = smtp.PlainAuth("", username, password, host)
+		}
+	}
+
+	return
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// checkTLS returns an error if the connection is not encrypted,
+// unless it is allowed or the server is localhost.
+func checkTLS(server *smtp.ServerInfo, insecure bool) error {
+	if !server.TLS && !insecure && !isLocalhost(server.Name) {
+		return errors.New("unencrypted connection")
+	}
+	return nil
+}
+
+/// ----------------------------------------------------------------------- ///
+
+type plainAuth struct {
+	identity, username, password string
+	host                         string
+}
+
+func newPlainAuth(identity, username, password, host string) smtp.Auth {
+	return &plainAuth{identity, username, password, host}
+}
+
+func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	resp := []byte(a.identity + "\x00" + a.username + "\x00" + a.password)
+	return "PLAIN", resp, nil
+}
+
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("unexpected server challenge")
+	}
+	return nil, nil
+}
+
+/// ----------------------------------------------------------------------- ///
+
+type loginAuth struct {
+	username string
+	password string
+	insecure bool
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkTLS(server, a.insecure); err != nil {
+		return "", nil, err
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	// Some servers, such as Exchange, use the different challenges,
+	// for example, "Username:", "User Name" or "Password:".
+	challenge := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(challenge, "user"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(challenge, "pass"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge '%s'", fromServer)
+	}
+}
+
+/// ----------------------------------------------------------------------- ///
+
+// tokenRefreshAhead is the duration to refresh the oauth2 token before it expires.
+const tokenRefreshAhead = time.Minute
+
+func newXOAuth2Auth(config map[string]any, username string, insecure bool) (smtp.Auth, error) {
+	token, err := getString(config, "oauth2token")
+	if err != nil {
+		return nil, err
+	}
+
+	tokenurl, err := getString(config, "oauth2tokenurl")
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &xoauth2Auth{username: username, token: token, insecure: insecure}
+	switch {
+	case tokenurl != "":
+		source := &oauth2TokenSource{url: tokenurl, do: http.DefaultClient.Do}
+		if source.clientid, err = getString(config, "oauth2clientid"); err != nil {
+			return nil, err
+		}
+		if source.clientsecret, err = getString(config, "oauth2clientsecret"); err != nil {
+			return nil, err
+		}
+		if source.refresh, err = getString(config, "oauth2refreshtoken"); err != nil {
+			return nil, err
+		}
+		if source.scopes, err = getStrings(config, "oauth2scopes", nil); err != nil {
+			return nil, err
+		}
+		auth.source = source
+
+	case token == "":
+		return nil, errors.New("oauth2token or oauth2tokenurl is required for xoauth2")
+	}
+
+	return auth, nil
+}
+
+type xoauth2Auth struct {
+	username string
+	token    string // Static token
+	source   *oauth2TokenSource
+	insecure bool
+}
+
+// Refresh refreshes the token before it expires with the context of sending,
+// which is called before connecting to the mail server.
+func (a *xoauth2Auth) Refresh(ctx context.Context) (err error) {
+	if a.source != nil {
+		_, err = a.source.Token(ctx)
+	}
+	return
+}
+
+// Start uses the token refreshed by Refresh, because it has no context.
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkTLS(server, a.insecure); err != nil {
+		return "", nil, err
+	}
+
+	token := a.token
+	if a.source != nil {
+		if token = a.source.Cached(); token == "" {
+			return "", nil, errors.New("the oauth2 token is not fetched")
+		}
+	}
+
+	resp := []byte("user=" + a.username + "\x01auth=Bearer " + token + "\x01\x01")
+	return "XOAUTH2", resp, nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server returns the error detail as the challenge,
+		// and expects an empty response to finish the authentication.
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// oauth2TokenSource fetches the access token from the oauth2 token endpoint
+// by the refresh token grant, or the client credentials grant
+// if the refresh token is empty.
+type oauth2TokenSource struct {
+	do  func(*http.Request) (*http.Response, error)
+	url string
+
+	clientid     string
+	clientsecret string
+	scopes       []string
+
+	lock    sync.Mutex
+	refresh string
+	token   string
+	renewat time.Time // The time to renew the token before it expires.
+}
+
+// Token returns the cached access token, or fetches a new one
+// if it is going to expire.
+func (s *oauth2TokenSource) Token(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" && (s.renewat.IsZero() || time.Now().Before(s.renewat)) {
+		return s.token, nil
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		return "", fmt.Errorf("fail to fetch the oauth2 token: %w", err)
+	}
+	return s.token, nil
+}
+
+// Cached returns the cached access token, which may be empty.
+func (s *oauth2TokenSource) Cached() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.token
+}
+
+func (s *oauth2TokenSource) fetch(ctx context.Context) (err error) {
+	form := make(url.Values, 5)
+	if s.refresh != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.refresh)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if s.clientid != "" {
+		form.Set("client_id", s.clientid)
+	}
+	if s.clientsecret != "" {
+		form.Set("client_secret", s.clientsecret)
+	}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if err = jsonx.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("status=%d, data=%s, err=%w", resp.StatusCode, data, err)
+	}
+
+	switch {
+	case result.Error != "":
+		return fmt.Errorf("%s: %s", result.Error, result.Description)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("status=%d, data=%s", resp.StatusCode, data)
+	case result.AccessToken == "":
+		return errors.New("no access token")
+	}
+
+	s.token = result.AccessToken
+	if result.RefreshToken != "" {
+		s.refresh = result.RefreshToken
+	}
+
+	if result.ExpiresIn > 0 {
+		expires := time.Duration(result.ExpiresIn) * time.Second
+		s.renewat = time.Now().Add(expires - min(tokenRefreshAhead, expires/2))
+	} else {
+		s.renewat = time.Time{}
+	}
+
+	return
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestInsecureAuth(t *testing.T) {
+	for _, c := range []struct {
+		config  map[string]any
+		host    string
+		tlsmode string
+		refused bool
+	}{
+		{config: map[string]any{"auth": "plain"}, host: "mail.example.com", tlsmode: tlsNone, refused: true},
+		{config: map[string]any{"auth": "login"}, host: "mail.example.com", tlsmode: tlsNone, refused: true},
+		{config: map[string]any{"auth": "xoauth2", "oauth2token": "token"}, host: "mail.example.com", tlsmode: tlsNone, refused: true},
+		{config: map[string]any{"auth": "plain", "allowinsecureauth": true}, host: "mail.example.com", tlsmode: tlsNone},
+		{config: map[string]any{"auth": "crammd5"}, host: "mail.example.com", tlsmode: tlsNone},
+		{config: map[string]any{"auth": "plain"}, host: "localhost", tlsmode: tlsNone},
+		{config: map[string]any{"auth": "login"}, host: "mail.example.com", tlsmode: tlsStartTLS},
+	} {
+		c.config["username"] = "user"
+		c.config["password"] = "pass"
+		_, err := newAuth(c.config, c.host, c.tlsmode)
+		if refused := err != nil && strings.Contains(err.Error(), "unencrypted"); refused != c.refused {
+			t.Errorf("%v %s %s: expect refused %v, but got %v", c.config, c.host, c.tlsmode, c.refused, err)
+		}
+	}
+
+	// The auth of the encrypted connection is refused if it is not encrypted actually.
+	server := &smtp.ServerInfo{Name: "mail.example.com", Auth: []string{"PLAIN", "LOGIN", "XOAUTH2"}}
+	for _, mechanism := range []string{"plain", "login", "xoauth2"} {
+		config := map[string]any{"auth": mechanism, "username": "user", "password": "pass", "oauth2token": "token"}
+		auth, err := newAuth(config, server.Name, tlsStartTLS)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err = auth.Start(server); err == nil {
+			t.Errorf("%s: expect an error for the unencrypted connection", mechanism)
+		}
+	}
+}
+
+func TestXOAuth2Refresh(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	config := map[string]any{"auth": "xoauth2", "username": "user", "oauth2tokenurl": server.URL}
+	auth, err := newAuth(config, "mail.example.com", tlsImplicit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &smtp.ServerInfo{Name: "mail.example.com", TLS: true}
+	if _, _, err := auth.Start(info); err == nil {
+		t.Errorf("expect an error before the token is fetched")
+	}
+
+	refresher := auth.(interface{ Refresh(context.Context) error })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := refresher.Refresh(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expect the canceled context is used, but got %v", err)
+	}
+
+	if err := refresher.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, resp, err := auth.Start(info)
+	if err != nil {
+		t.Fatal(err)
+	} else if expect := "user=user\x01auth=Bearer token\x01\x01"; string(resp) != expect {
+		t.Errorf("expect response %q, but got %q", expect, resp)
+	}
+	if calls != 1 {
+		t.Errorf("expect the token is fetched once, but got %d", calls)
+	}
+}
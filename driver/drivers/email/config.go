@@ -0,0 +1,138 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func getString(config map[string]any, key string) (string, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unsupported %s type %T", key, v)
+	}
+}
+
+func getStrings(config map[string]any, key string, defaults []string) ([]string, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return defaults, nil
+
+	case string:
+		return strings.Fields(v), nil
+
+	case []string:
+		return v, nil
+
+	case []any:
+		values := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s[%d] expects a string, but got %T", key, i, e)
+			}
+			values[i] = s
+		}
+		return values, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported %s type %T", key, v)
+	}
+}
+
+func getInt(config map[string]any, key string, defaultValue int) (int, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return defaultValue, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	case float64: // For JSON
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("unsupported %s type %T", key, v)
+	}
+}
+
+func getBool(config map[string]any, key string, defaultValue bool) (bool, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return defaultValue, nil
+	case bool:
+		return v, nil
+
+	case int:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case uint:
+		return v != 0, nil
+	case uint64:
+		return v != 0, nil
+	case float64: // For JSON
+		return v != 0, nil
+
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s: %s", key, err)
+		}
+		return b, nil
+
+	default:
+		return false, fmt.Errorf("unsupported %s type %T", key, v)
+	}
+}
+
+// getDuration parses the duration option, and the integer stands for second.
+func getDuration(config map[string]any, key string, defaultValue time.Duration) (time.Duration, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return defaultValue, nil
+
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case uint:
+		return time.Duration(v) * time.Second, nil
+	case uint64:
+		return time.Duration(v) * time.Second, nil
+	case float64: // For JSON
+		return time.Duration(v * float64(time.Second)), nil
+
+	case string:
+		t, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", key, err)
+		}
+		return t, nil
+
+	default:
+		return 0, fmt.Errorf("unsupported %s type %T", key, v)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDKIMHeaders is the default headers to be signed if they exist.
+var defaultDKIMHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc",
+	"Message-Id", "In-Reply-To", "References",
+	"Mime-Version", "Content-Type", "Content-Transfer-Encoding",
+	"List-Id", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// dkimSigner signs the email by DKIM, see RFC 6376 and RFC 8463,
+// which uses the relaxed/relaxed canonicalization.
+type dkimSigner struct {
+	domain   string
+	selector string
+	headers  []string // The lower-case header names.
+
+	algorithm string
+	signer    crypto.Signer
+}
+
+// newDKIMSigner builds the dkim signer from the config.
+//
+// Return nil if dkimdomain is not set.
+func newDKIMSigner(config map[string]any) (s *dkimSigner, err error) {
+	domain, err := getString(config, "dkimdomain")
+	if err != nil || domain == "" {
+		return
+	}
+
+	selector, err := getString(config, "dkimselector")
+	if err != nil {
+		return
+	} else if selector == "" {
+		return nil, errors.New("dkimselector is missing or invalid")
+	}
+
+	key, err := getString(config, "dkimkey")
+	if err != nil {
+		return
+	} else if key == "" {
+		keyfile, err := getString(config, "dkimkeyfile")
+		if err != nil {
+			return nil, err
+		} else if keyfile == "" {
+			return nil, errors.New("dkimkey or dkimkeyfile is required for dkim")
+		}
+
+		data, err := os.ReadFile(keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dkimkeyfile: %w", err)
+		}
+		key = string(data)
+	}
+
+	headers, err := getStrings(config, "dkimheaders", defaultDKIMHeaders)
+	if err != nil {
+		return
+	}
+
+	s = &dkimSigner{domain: domain, selector: selector, headers: make([]string, len(headers))}
+	for i, header := range headers {
+		s.headers[i] = strings.ToLower(header)
+	}
+	if !slices.Contains(s.headers, "from") {
+		return nil, errors.New("dkimheaders must contain From")
+	}
+
+	if s.algorithm, s.signer, err = parseDKIMKey(key); err != nil {
+		return nil, fmt.Errorf("invalid dkim key: %w", err)
+	}
+
+	return
+}
+
+func parseDKIMKey(key string) (algorithm string, signer crypto.Signer, err error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return "", nil, errors.New("no PEM block")
+	}
+
+	var privkey any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privkey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privkey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+	}
+	if err != nil {
+		return
+	}
+
+	switch k := privkey.(type) {
+	case *rsa.PrivateKey:
+		return "rsa-sha256", k, nil
+	case ed25519.PrivateKey:
+		return "ed25519-sha256", k, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported private key type %T", privkey)
+	}
+}
+
+// Sign signs the email message and returns the new one
+// with the DKIM-Signature header.
+//
+// The line endings of the message are normalized to CRLF.
+func (s *dkimSigner) Sign(msg []byte) ([]byte, error) {
+	msg = toCRLF(msg)
+
+	var header, body []byte
+	if index := bytes.Index(msg, []byte("\r\n\r\n")); index < 0 {
+		header = msg
+	} else {
+		header, body = msg[:index+2], msg[index+4:]
+	}
+
+	bodyhash := sha256.Sum256(relaxedBody(body))
+	fields := splitHeaderFields(header)
+
+	// Sign the last instance of the header first, see RFC 6376, 5.4.2.
+	var names []string
+	hash := sha256.New()
+	used := make(map[string]int, len(s.headers))
+	for _, name := range s.headers {
+		if field, ok := lastHeaderField(fields, name, used[name]); ok {
+			used[name]++
+			names = append(names, name)
+			hash.Write([]byte(relaxedHeader(field)))
+		}
+	}
+	if !slices.Contains(names, "from") {
+		return nil, errors.New("dkim: missing the From header")
+	}
+
+	var b strings.Builder
+	b.Grow(512)
+	b.WriteString("DKIM-Signature: v=1; a=")
+	b.WriteString(s.algorithm)
+	b.WriteString("; c=relaxed/relaxed;\r\n\td=")
+	b.WriteString(s.domain)
+	b.WriteString("; s=")
+	b.WriteString(s.selector)
+	b.WriteString("; t=")
+	b.WriteString(strconv.FormatInt(time.Now().Unix(), 10))
+	b.WriteString(";\r\n\th=")
+	b.WriteString(strings.Join(names, ":"))
+	b.WriteString(";\r\n\tbh=")
+	b.WriteString(base64.StdEncoding.EncodeToString(bodyhash[:]))
+	b.WriteString(";\r\n\tb=")
+
+	// The DKIM-Signature header itself is signed without the trailing CRLF.
+	hash.Write([]byte(strings.TrimSuffix(relaxedHeader(b.String()), "\r\n")))
+	digest := hash.Sum(nil)
+
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := s.signer.(ed25519.PrivateKey); ok {
+		opts = crypto.Hash(0) // Ed25519 signs the SHA-256 digest as the message.
+	}
+
+	signature, err := s.signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: fail to sign: %w", err)
+	}
+
+	sig := base64.StdEncoding.EncodeToString(signature)
+	for len(sig) > 72 {
+		b.WriteString(sig[:72])
+		b.WriteString("\r\n\t")
+		sig = sig[72:]
+	}
+	b.WriteString(sig)
+	b.WriteString("\r\n")
+
+	signed := make([]byte, 0, b.Len()+len(msg))
+	signed = append(signed, b.String()...)
+	signed = append(signed, msg...)
+	return signed, nil
+}
+
+// toCRLF converts the bare LF to CRLF.
+func toCRLF(msg []byte) []byte {
+	if bytes.Count(msg, []byte("\n")) == bytes.Count(msg, []byte("\r\n")) {
+		return msg
+	}
+
+	buf := make([]byte, 0, len(msg)+len(msg)/32)
+	for i, c := range msg {
+		if c == '\n' && (i == 0 || msg[i-1] != '\r') {
+			buf = append(buf, '\r')
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// splitHeaderFields splits the header into the raw fields,
+// each of which contains the folded lines and the trailing CRLF.
+func splitHeaderFields(header []byte) (fields []string) {
+	for len(header) > 0 {
+		end := 0
+		for {
+			index := bytes.Index(header[end:], []byte("\r\n"))
+			if index < 0 {
+				end = len(header)
+				break
+			}
+
+			end += index + 2
+			if end >= len(header) || (header[end] != ' ' && header[end] != '\t') {
+				break
+			}
+		}
+
+		fields = append(fields, string(header[:end]))
+		header = header[end:]
+	}
+	return
+}
+
+// lastHeaderField returns the (skip+1)th header field named name from the bottom.
+func lastHeaderField(fields []string, name string, skip int) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		key, _, ok := strings.Cut(fields[i], ":")
+		if ok && strings.EqualFold(strings.TrimRight(key, " \t"), name) {
+			if skip == 0 {
+				return fields[i], true
+			}
+			skip--
+		}
+	}
+	return "", false
+}
+
+// relaxedHeader canonicalizes the header field by the relaxed algorithm.
+func relaxedHeader(field string) string {
+	key, value, _ := strings.Cut(field, ":")
+	key = strings.ToLower(strings.TrimRight(key, " \t"))
+
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	value = strings.Trim(collapseWSP(value), " ")
+	return key + ":" + value + "\r\n"
+}
+
+// relaxedBody canonicalizes the body by the relaxed algorithm.
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	var b strings.Builder
+	b.Grow(len(body))
+
+	var empty int
+	for _, line := range lines {
+		line = strings.TrimRight(collapseWSP(line), " ")
+		if line == "" {
+			empty++
+			continue
+		}
+
+		for ; empty > 0; empty-- {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	return []byte(b.String())
+}
+
+// collapseWSP replaces each sequence of the whitespaces with a single space.
+func collapseWSP(s string) string {
+	if !strings.ContainsAny(s, "\t") && !strings.Contains(s, "  ") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	var space bool
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			space = true
+			continue
+		}
+
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,171 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDKIMRelaxed(t *testing.T) {
+	// The example in RFC 6376, 3.4.5.
+	fields := splitHeaderFields([]byte("A: X\r\nB : Y\t\r\n\tZ  \r\n"))
+	if len(fields) != 2 {
+		t.Fatalf("expect 2 header fields, but got %d: %q", len(fields), fields)
+	}
+
+	var header string
+	for _, field := range fields {
+		header += relaxedHeader(field)
+	}
+	if expect := "a:X\r\nb:Y Z\r\n"; header != expect {
+		t.Errorf("expect header %q, but got %q", expect, header)
+	}
+
+	body := string(relaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n")))
+	if expect := " C\r\nD E\r\n"; body != expect {
+		t.Errorf("expect body %q, but got %q", expect, body)
+	}
+}
+
+var dkimSigTag = regexp.MustCompile(`(^|;)(\s*b\s*=)[^;]*`)
+
+// verifyDKIM verifies the first DKIM-Signature of the message by the public key.
+func verifyDKIM(msg []byte, pubkey crypto.PublicKey) error {
+	index := bytes.Index(msg, []byte("\r\n\r\n"))
+	header, body := msg[:index+2], msg[index+4:]
+	fields := splitHeaderFields(header)
+
+	signature := fields[0]
+	name, value, _ := strings.Cut(signature, ":")
+	if name != "DKIM-Signature" {
+		return errors.New("missing DKIM-Signature")
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		key, value, _ := strings.Cut(tag, "=")
+		value = strings.Join(strings.Fields(value), "")
+		tags[strings.TrimSpace(key)] = value
+	}
+
+	bodyhash := sha256.Sum256(relaxedBody(body))
+	if bh := base64.StdEncoding.EncodeToString(bodyhash[:]); tags["bh"] != bh {
+		return errors.New("body hash mismatch")
+	}
+
+	hash := sha256.New()
+	used := make(map[string]int)
+	for _, name := range strings.Split(tags["h"], ":") {
+		field, ok := lastHeaderField(fields[1:], name, used[name])
+		if !ok {
+			return errors.New("missing the signed header " + name)
+		}
+		used[name]++
+		hash.Write([]byte(relaxedHeader(field)))
+	}
+	signature = dkimSigTag.ReplaceAllString(signature, "$1$2")
+	hash.Write([]byte(strings.TrimSuffix(relaxedHeader(signature), "\r\n")))
+	digest := hash.Sum(nil)
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+
+	switch key := pubkey.(type) {
+	case *rsa.PublicKey:
+		if tags["a"] != "rsa-sha256" {
+			return errors.New("unexpected algorithm " + tags["a"])
+		}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig)
+
+	case ed25519.PublicKey:
+		if tags["a"] != "ed25519-sha256" {
+			return errors.New("unexpected algorithm " + tags["a"])
+		}
+		if !ed25519.Verify(key, digest, sig) {
+			return errors.New("invalid ed25519 signature")
+		}
+		return nil
+
+	default:
+		return errors.New("unsupported public key")
+	}
+}
+
+func TestDKIMSign(t *testing.T) {
+	rsakey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edkey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("From: Sender <from@example.com>\r\nTo: to@example.com\r\n" +
+		"Subject:  Hello\r\n  World\r\nX-Unsigned: value\r\n\r\nBody  line\r\n\r\n\r\n")
+	for _, key := range []crypto.Signer{rsakey, edkey} {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := newDKIMSigner(map[string]any{
+			"dkimdomain":   "example.com",
+			"dkimselector": "default",
+			"dkimkey":      string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, err := s.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := verifyDKIM(signed, key.Public()); err != nil {
+			t.Errorf("%s: %v", s.algorithm, err)
+		}
+
+		tampered := bytes.Replace(signed, []byte("World"), []byte("Earth"), 1)
+		if err := verifyDKIM(tampered, key.Public()); err == nil {
+			t.Errorf("%s: expect the tampered header fails to be verified", s.algorithm)
+		}
+
+		tampered = bytes.Replace(signed, []byte("Body"), []byte("Text"), 1)
+		if err := verifyDKIM(tampered, key.Public()); err == nil {
+			t.Errorf("%s: expect the tampered body fails to be verified", s.algorithm)
+		}
+
+		unsigned := bytes.Replace(signed, []byte("X-Unsigned: value"), []byte("X-Unsigned: other"), 1)
+		if err := verifyDKIM(unsigned, key.Public()); err != nil {
+			t.Errorf("%s: expect the changed unsigned header is still verified: %v", s.algorithm, err)
+		}
+	}
+}
@@ -17,247 +17,235 @@ package email
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net"
-	"net/smtp"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/knadh/smtppool"
 	"github.com/xgfone/go-msgnotice/driver"
 	"github.com/xgfone/go-msgnotice/driver/builder"
 	"github.com/xgfone/go-msgnotice/tools/email"
-	"github.com/xgfone/go-toolkit/unsafex"
 )
 
 // DriverType represents the driver type "email".
 const DriverType = "email"
 
 // DecodeMessageContent is used to decode the content of the email message.
+//
+// Deprecated: it only decodes the subject and content and is not used
+// by the driver any more, use DecodeMessage instead.
 var DecodeMessageContent = email.DecodeMessage
 
+// DecodeMessage is used to decode the content of the email message
+// with the text, headers and attachments.
+var DecodeMessage = email.Decode
+
 // Message is the alias of email.Message.
 type Message = email.Message
 
+// Attachment is the alias of email.Attachment.
+type Attachment = email.Attachment
+
 func init() { builder.NewAndRegister(DriverType, New) }
 
-// New returns a new driver, which sends the message by the html email,
+// New returns a new driver, which sends the message by the html email
+// with the plain text alternative, or by the plain text email,
 // which is registered as the driver builder with name "email"
 // and type DriverType by default.
 //
 // config options:
 //
-//	addr(string, required): the mail server address, such as "mail.examole.com".
+//	addr(string, required): the mail server address, such as "mail.examole.com". It is optional if servers is set.
 //	from(string, required): the adddress to send email, such as "username@mail.example.com".
-//	username(string, required): the username to login the mail server, such as "username@mail.example.com".
-//	password(string, required): the password to login the mail server, such as "password".
+//	allowfrom([]string|string, optional): the addresses or domains like "@example.com" allowed as the per-message sender, separated by the whitespace for string.
+//	unsubscribeurl(string, optional): the https url template to unsubscribe by one click, such as "https://example.com/unsubscribe?email={receiver}".
+//	unsubscribemailto(string, optional): the address to unsubscribe by email, such as "unsubscribe@example.com".
+//	auth(string, optional): the authentication mechanism, such as "none", "plain", "login", "crammd5" or "xoauth2".
+//	username(string, optional): the username to login the mail server, such as "username@mail.example.com".
+//	password(string, optional): the password to login the mail server, such as "password".
+//	allowinsecureauth(int|int64|uint|uint64|string|bool, optional): if true, allow "plain", "login" and "xoauth2" over the unencrypted connection. default false.
+//	oauth2token(string, optional): the static bearer token for "xoauth2".
+//	oauth2tokenurl(string, optional): the oauth2 token endpoint to fetch and refresh the bearer token for "xoauth2".
+//	oauth2clientid(string, optional): the oauth2 client id.
+//	oauth2clientsecret(string, optional): the oauth2 client secret.
+//	oauth2refreshtoken(string, optional): the oauth2 refresh token. If empty, use the client credentials grant.
+//	oauth2scopes([]string|string, optional): the oauth2 scopes, such as "https://outlook.office365.com/.default".
+//	tls(string, optional): the tls mode, such as "none", "starttls" or "implicit".
 //	forcetls(int|int64|uint|uint64|string|bool, optional): if true, force to use TLS. For integer, 0 is false else true.
+//	tlscafile(string, optional): the file of the PEM CA bundle to verify the mail server. default use the system CA.
+//	tlscertfile(string, optional): the file of the PEM client certificate, which must be used with tlskeyfile.
+//	tlskeyfile(string, optional): the file of the PEM private key of the client certificate.
+//	tlsservername(string, optional): the server name to verify the certificate of the mail server. default the host of addr.
+//	tlsminversion(string, optional): the minimum TLS version, such as "1.0", "1.1", "1.2" or "1.3".
+//	attachmentdir(string, optional): the directory where the attachment files are located. If empty, disable the attachment file.
+//	maxattachmentsize(int|int64|uint|uint64, optional): the maximum total size in bytes of all the attachments. default 10MB, 0 means no limit.
+//	dkimdomain(string, optional): the signing domain of DKIM, such as "example.com". If empty, disable DKIM.
+//	dkimselector(string, optional): the selector of DKIM, which is required if dkimdomain is set.
+//	dkimkey(string, optional): the PEM private key of DKIM, RSA or Ed25519, in PKCS#1 or PKCS#8.
+//	dkimkeyfile(string, optional): the file of the PEM private key of DKIM if dkimkey is not set.
+//	dkimheaders([]string|string, optional): the headers to be signed if they exist, which must contain "From".
+//	smimecertfile(string, optional): the file of the PEM S/MIME certificate with the chain to sign the email, which must be used with smimekeyfile.
+//	smimekeyfile(string, optional): the file of the PEM private key of the S/MIME certificate.
+//	pgpkey(string, optional): the armored PGP private key to sign the email.
+//	pgpkeyfile(string, optional): the file of the armored PGP private key if pgpkey is not set.
+//	pgppassphrase(string, optional): the passphrase to decrypt the PGP private key.
+//	pgpencrypt(string, optional): the PGP encryption mode, such as "none", "optional" or "required". default "none".
+//	pgpkeys(map[string]string, optional): the armored PGP public keys of the recipients indexed by the address.
+//	pgplookup(func(context.Context, string) (string, error), optional): the function to look up the armored PGP public key of the recipient address not in pgpkeys, which returns "" if no key.
 //	timeout(int|int64|uint|uint64|string, optional): the timeout. If integer, stand for second. default 3s.
 //	idletimeout(int|int64|uint|uint64|string, optional): time idle timeout. If integer, stand for second. default 1m.
 //	maxconnnum(int|int64|uint|uint64, optional): the maximum number of the connection, default 100.
+//	servers([]map[string]any, optional): the mail servers, each of which supports the options addr, auth, username,
+//	    password, allowinsecureauth, oauth2*, tls, forcetls, tls*, timeout, idletimeout, maxconnnum and weight, and inherits them if missing.
+//	strategy(string, optional): the strategy to select the mail server, such as "failover", "roundrobin" or "weighted". default "failover".
+//	cooldown(int|int64|uint|uint64|string, optional): the duration that an unhealthy server is not preferred. If integer, stand for second. default 30s.
+//	weight(int|int64|uint|uint64, optional): the weight of the server for the strategy "weighted". default 1.
+//	sendmode(string, optional): the mode to send the email to the receivers, such as "batch" or "individual". default "batch".
+//	concurrency(int|int64|uint|uint64, optional): the maximum number of the emails sent concurrently in the "individual" mode. default 1.
+//
+// If auth is not set, it is "plain" if username is set, else "none".
+// username and password are required by "plain", "login" and "crammd5",
+// and username and one of oauth2token and oauth2tokenurl are required by "xoauth2".
+// For oauth2tokenurl, the token is refreshed before it expires.
+// For tls "none", "plain", "login" and "xoauth2" are refused unless the mail
+// server is localhost or allowinsecureauth is true, because the credential
+// is sent in cleartext.
+//
+// If tls is not set, it is "implicit" if forcetls is true, else "starttls".
+//
+// If addr does not contain the port, use 465 for "implicit", 587 for "starttls"
+// and 25 for "none". But for compatibility, use 25 instead of 587 for "starttls"
+// if tls is not set.
+//
+// The attachment with the path is read from the file relative to attachmentdir,
+// which can not be outside of attachmentdir.
+//
+// If the ContentType of the message is "html" or empty, the email is sent
+// as multipart/alternative with the Text of the message, which is rendered
+// from the html content if empty. If it is "text", send the plain text only.
+//
+// The From, Cc, Bcc, ReplyTo and Headers of the email can be set by the message
+// content or the message metadata with the same keys, see Message.ApplyMetadata.
+// The per-message From is allowed only if it is the configured from with another
+// display name, or its address or domain is in allowfrom. Headers cannot override
+// the headers built by the driver, such as From, To, Subject and Content-Type.
+//
+// Each email has a unique Message-ID in the domain of from, which is reported
+// by driver.AddMessageID after the email is sent. If MessageKey is set, it is
+// derived from MessageKey so that the retried or resent email has the same
+// one, else it is random for each send. If ThreadKey is set, the emails
+// with the same ThreadKey reply to the same virtual root email by In-Reply-To
+// and References, so that they are threaded in the email clients.
+//
+// If unsubscribeurl or unsubscribemailto is set, add the List-Unsubscribe header,
+// and List-Unsubscribe-Post for unsubscribeurl by RFC 8058. In unsubscribeurl,
+// "{receiver}" is replaced with the comma-separated addresses in To, and
+// "{messageid}" with the Message-ID without "<>", both of which are query-escaped.
+//
+// If dkimdomain is set, the email is signed by DKIM with the relaxed/relaxed
+// canonicalization, and the algorithm, "rsa-sha256" or "ed25519-sha256",
+// is decided by the type of the private key. dkimheaders defaults to From,
+// Reply-To, Subject, Date, To, Cc, Message-Id, In-Reply-To, References,
+// Mime-Version, Content-Type, Content-Transfer-Encoding and the List-* headers.
+//
+// If smimecertfile is set, the email is signed by S/MIME as multipart/signed.
+// Or, if pgpkey or pgpencrypt is set, the email is signed and/or encrypted
+// by PGP/MIME. For pgpencrypt, the email is encrypted to the keys of all
+// the recipients, which are looked up from pgpkeys, then by pgplookup.
+// If some recipient has no key, the email is sent without the encryption
+// for "optional", but fails for "required". S/MIME and PGP cannot be used
+// together, and DKIM signs the email after them.
+//
+// If servers is set, the email is sent by the server selected by strategy.
+// For "failover", the servers are tried in order. For "roundrobin", they are
+// tried in turn. For "weighted", they are selected by the smooth weighted
+// round-robin. If it fails to connect to or authenticate with a server,
+// the server is marked as unhealthy for cooldown and the next one is tried.
+// The unhealthy servers are only tried after all the healthy ones fail.
 //
-// If addr does not contain the port, use 465 if forcetls is true else 25.
+// For sendmode "batch", one email is sent to all the receivers together.
+// For "individual", each receiver gets its own email, which does not support
+// Cc and Bcc. If the email fails to be sent to some receivers, RecipientsError
+// is returned with the results of all the receivers, such as the SMTP reply code.
 //
-// Notice: The returned driver supports the comma-separated receiver list.
+// Notice: The returned driver supports the comma-separated receiver list,
+// which is parsed by RFC 5322, see email.ParseAddressList.
 func New(name string, config map[string]any) (driver.Driver, error) {
-	addr, _ := config["addr"].(string)
-	from, _ := config["from"].(string)
-	username, _ := config["username"].(string)
-	password, _ := config["password"].(string)
-	if addr == "" {
-		return nil, errors.New("addr is missing or invalid")
-	}
-	if from == "" {
-		return nil, errors.New("from is missing or invalid")
-	}
-	if username == "" {
-		return nil, errors.New("username is missing or invalid")
-	}
-	if password == "" {
-		return nil, errors.New("password is missing or invalid")
-	}
-
-	var maxconnnum int
-	switch v := config["maxconnnum"].(type) {
-	case nil:
-		maxconnnum = 100
-	case int:
-		maxconnnum = v
-	case int64:
-		maxconnnum = int(v)
-	case uint:
-		maxconnnum = int(v)
-	case uint64:
-		maxconnnum = int(v)
-	default:
-		return nil, fmt.Errorf("unsupported maxconnnum type %T", v)
-	}
-
-	var timeout time.Duration
-	switch v := config["timeout"].(type) {
-	case nil:
-		timeout = 3 * time.Second
-
-	case int:
-		timeout = time.Duration(v) * time.Second
-	case int64:
-		timeout = time.Duration(v) * time.Second
-	case uint:
-		timeout = time.Duration(v) * time.Second
-	case uint64:
-		timeout = time.Duration(v) * time.Second
-
-	case string:
-		t, err := time.ParseDuration(v)
-		if err != nil {
-			return nil, fmt.Errorf("invalid timeout: %s", err)
-		}
-		timeout = t
-
-	default:
-		return nil, fmt.Errorf("unsupported timeout type %T", v)
+	eb, err := newEmailBuilder(name, config)
+	if err != nil {
+		return nil, err
 	}
 
-	var idleTimeout time.Duration
-	switch v := config["idletimeout"].(type) {
-	case nil:
-		idleTimeout = time.Minute
-
-	case int:
-		idleTimeout = time.Duration(v) * time.Second
-	case int64:
-		idleTimeout = time.Duration(v) * time.Second
-	case uint:
-		idleTimeout = time.Duration(v) * time.Second
-	case uint64:
-		idleTimeout = time.Duration(v) * time.Second
-
-	case string:
-		t, err := time.ParseDuration(v)
-		if err != nil {
-			return nil, fmt.Errorf("invalid idle timeout: %s", err)
-		}
-		idleTimeout = t
-
-	default:
-		return nil, fmt.Errorf("unsupported idle timeout type %T", v)
+	sendmode, err := getString(config, "sendmode")
+	if err != nil {
+		return nil, err
 	}
-
-	var forceTLS bool
-	switch v := config["forcetls"].(type) {
-	case nil:
-	case bool:
-		forceTLS = v
-
-	case int:
-		forceTLS = v != 0
-	case int64:
-		forceTLS = v != 0
-	case uint:
-		forceTLS = v != 0
-	case uint64:
-		forceTLS = v != 0
-
-	case string:
-		b, err := strconv.ParseBool(v)
-		if err != nil {
-			return nil, fmt.Errorf("invalid forcetls: %s", err)
-		}
-		forceTLS = b
-
+	switch sendmode = strings.ToLower(sendmode); sendmode {
+	case "":
+		sendmode = sendModeBatch
+	case sendModeBatch, sendModeIndividual:
 	default:
-		return nil, fmt.Errorf("unsupported forcetls type %T", v)
+		return nil, fmt.Errorf("unsupported sendmode '%s'", sendmode)
 	}
 
-	var port int
-	var host string
-	if _host, _port, err := net.SplitHostPort(addr); err == nil {
-		host = _host
-		v, err := strconv.ParseUint(_port, 10, 16)
-		if err != nil {
-			return nil, fmt.Errorf("invalid port: %w", err)
-		}
-		port = int(v)
-	} else {
-		host = addr
-		if forceTLS {
-			port = 465
-		} else {
-			port = 25
-		}
-	}
-
-	var auth smtp.Auth
-	if forceTLS {
-		auth = smtp.PlainAuth("", username, password, host)
-	} else {
-		auth = newPlainAuth("", username, password, host)
+	concurrency, err := getInt(config, "concurrency", 1)
+	if err != nil {
+		return nil, err
+	} else if concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d", concurrency)
 	}
 
-	opt := smtppool.Opt{
-		Host:            host,
-		Port:            port,
-		Auth:            auth,
-		MaxConns:        maxconnnum,
-		IdleTimeout:     idleTimeout,
-		PoolWaitTimeout: timeout,
-
-		TLSConfig: nil,
-		SSL:       forceTLS,
-	}
-	pool, err := smtppool.New(opt)
+	servers, err := newServerGroup(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return driverImpl{name: name, from: from, pool: pool}, nil
+	return driverImpl{
+		name:    name,
+		servers: servers,
+		builder: eb,
+
+		individual:  sendmode == sendModeIndividual,
+		concurrency: concurrency,
+	}, nil
 }
 
 type driverImpl struct {
-	pool *smtppool.Pool
-	from string
-	name string
+	builder emailBuilder
+	servers *serverGroup
+	name    string
+
+	individual  bool
+	concurrency int
 }
 
-func (d driverImpl) Stop()        { d.pool.Close() }
+func (d driverImpl) Stop()        { d.servers.Close() }
 func (d driverImpl) Name() string { return d.name }
 func (d driverImpl) Type() string { return DriverType }
 func (d driverImpl) Send(c context.Context, m driver.Message) (err error) {
-	subject, content, err := DecodeMessageContent(m.Content)
+	mail, err := d.builder.Build(m)
 	if err != nil {
 		return
 	}
 
-	var mail smtppool.Email
-	mail.From = d.from
-	mail.To = strings.Split(m.Receiver, ",")
-	mail.Subject = subject
-	mail.HTML = unsafex.Bytes(content)
-	return d.pool.Send(mail)
-}
-
-type plainAuth struct {
-	identity, username, password string
-	host                         string
+	if d.individual {
+		return d.sendIndividually(c, mail)
+	}
+	return d.send(c, mail)
 }
 
-func newPlainAuth(identity, username, password, host string) smtp.Auth {
-	return &plainAuth{identity, username, password, host}
-}
+func (d driverImpl) send(c context.Context, mail smtppool.Email) (err error) {
+	from, to, err := getEnvelope(mail)
+	if err != nil {
+		return
+	}
 
-func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
-	if server.Name != a.host {
-		return "", nil, errors.New("wrong host name")
+	data, err := d.builder.Encode(c, mail)
+	if err != nil {
+		return
 	}
-	resp := []byte(a.identity + "\x00" + a.username + "\x00" + a.password)
-	return "PLAIN", resp, nil
-}
 
-func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
-	if more {
-		return nil, errors.New("unexpected server challenge")
+	if err = d.servers.Send(c, from, to, data); err == nil {
+		driver.AddMessageID(c, mail.Headers.Get(smtppool.HdrMessageID))
 	}
-	return nil, nil
+	return
 }
@@ -0,0 +1,164 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/builder"
+	"github.com/xgfone/go-toolkit/jsonx"
+)
+
+// DriverTypeMailgun represents the driver type "mailgun".
+const DriverTypeMailgun = "mailgun"
+
+func init() { builder.NewAndRegister(DriverTypeMailgun, NewMailgun) }
+
+// NewMailgun returns a new driver, which builds the same email as the driver
+// "email" and sends it by the Mailgun messages api in the MIME format,
+// which is registered as the driver builder with name "mailgun"
+// and type DriverTypeMailgun by default.
+//
+// config options:
+//
+//	apikey(string, required): the api key of Mailgun.
+//	domain(string, required): the sending domain of Mailgun, such as "mg.example.com".
+//	from(string, required): the address to send email, such as "username@mg.example.com".
+//	allowfrom([]string|string, optional): the addresses or domains like "@example.com" allowed as the per-message sender, separated by the whitespace for string.
+//	unsubscribeurl(string, optional): the https url template to unsubscribe by one click, such as "https://example.com/unsubscribe?email={receiver}".
+//	unsubscribemailto(string, optional): the address to unsubscribe by email, such as "unsubscribe@example.com".
+//	endpoint(string, optional): the base url of the api, such as "https://api.eu.mailgun.net" for EU. default "https://api.mailgun.net".
+//	attachmentdir(string, optional): the directory where the attachment files are located. If empty, disable the attachment file.
+//	maxattachmentsize(int|int64|uint|uint64, optional): the maximum total size in bytes of all the attachments. default 10MB, 0 means no limit.
+//	timeout(int|int64|uint|uint64|string, optional): the timeout to call the api. If integer, stand for second. default 10s.
+//
+// The message content and metadata support the same fields as the driver "email",
+// and so do Message-ID, MessageKey, ThreadKey, List-Unsubscribe, DKIM, S/MIME and PGP/MIME.
+// The message id returned by Mailgun is reported by driver.AddMessageID.
+//
+// Notice: The returned driver supports the comma-separated receiver list,
+// which is parsed by RFC 5322, see email.ParseAddressList.
+func NewMailgun(name string, config map[string]any) (driver.Driver, error) {
+	eb, err := newEmailBuilder(name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	apikey, err := getString(config, "apikey")
+	if err != nil {
+		return nil, err
+	} else if apikey == "" {
+		return nil, errors.New("apikey is missing or invalid")
+	}
+
+	domain, err := getString(config, "domain")
+	if err != nil {
+		return nil, err
+	} else if domain == "" {
+		return nil, errors.New("domain is missing or invalid")
+	}
+
+	client, err := newAPIClient(config, DriverTypeMailgun, "https://api.mailgun.net")
+	if err != nil {
+		return nil, err
+	}
+
+	return mailgunDriver{
+		name:    name,
+		builder: eb,
+		client:  client,
+		apikey:  apikey,
+		url:     fmt.Sprintf("%s/v3/%s/messages.mime", client.endpoint, url.PathEscape(domain)),
+	}, nil
+}
+
+type mailgunDriver struct {
+	builder emailBuilder
+	client  apiClient
+	apikey  string
+	name    string
+	url     string
+}
+
+func (d mailgunDriver) Stop()        {}
+func (d mailgunDriver) Name() string { return d.name }
+func (d mailgunDriver) Type() string { return DriverTypeMailgun }
+func (d mailgunDriver) Send(c context.Context, m driver.Message) (err error) {
+	mail, err := d.builder.Build(m)
+	if err != nil {
+		return
+	}
+
+	_, to, err := getEnvelope(mail)
+	if err != nil {
+		return
+	}
+
+	data, err := d.builder.Encode(c, mail)
+	if err != nil {
+		return
+	}
+
+	var body bytes.Buffer
+	body.Grow(len(data) + 1024)
+	w := multipart.NewWriter(&body)
+	for _, addr := range to {
+		if err = w.WriteField("to", addr); err != nil {
+			return
+		}
+	}
+
+	part, err := w.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return
+	}
+	if _, err = part.Write(data); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, &body)
+	if err != nil {
+		return
+	}
+
+	resp, _, err := d.client.Do(c, req, func(r *http.Request) error {
+		r.SetBasicAuth("api", d.apikey)
+		r.Header.Set("Content-Type", w.FormDataContentType())
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err = jsonx.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("mailgun: invalid response '%s': %w", resp, err)
+	}
+
+	driver.AddMessageID(c, result.ID)
+	return
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestMailgun(t *testing.T) {
+	endpoint, req := newAPIServer(t, 200, nil, `{"id":"<mgid@mg.example.com>","message":"Queued"}`)
+	d, err := NewMailgun("mailgun", map[string]any{
+		"apikey":   "key",
+		"domain":   "mg.example.com",
+		"from":     "sender@mg.example.com",
+		"endpoint": endpoint + "/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result driver.Result
+	c := driver.WithResult(context.Background(), &result)
+	msg := Message{Subject: "subject", Content: "content", Bcc: []string{"bcc@example.com"}}
+	if err := d.Send(c, driver.NewMessage("", "", "to@example.com", msg, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "POST" || req.Path != "/v3/mg.example.com/messages.mime" {
+		t.Errorf("unexpected request %s %s", req.Method, req.Path)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "Basic "+base64.StdEncoding.EncodeToString([]byte("api:key")) {
+		t.Errorf("unexpected Authorization '%s'", auth)
+	}
+	if ids := result.MessageIDs(); !reflect.DeepEqual(ids, []string{"<mgid@mg.example.com>"}) {
+		t.Errorf("unexpected message ids %v", ids)
+	}
+
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var to []string
+	var message string
+	r := multipart.NewReader(bytes.NewReader(req.Body), params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(part)
+		switch part.FormName() {
+		case "to":
+			to = append(to, string(data))
+		case "message":
+			message = string(data)
+		}
+	}
+
+	if !reflect.DeepEqual(to, []string{"to@example.com", "bcc@example.com"}) {
+		t.Errorf("unexpected recipients %v", to)
+	}
+	if !strings.Contains(message, "Subject: subject\r\n") || strings.Contains(message, "bcc@example.com") {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	testAPIError(t, func(endpoint string) (driver.Driver, error) {
+		return NewMailgun("mailgun", map[string]any{"apikey": "key", "domain": "mg.example.com",
+			"from": "sender@mg.example.com", "endpoint": endpoint})
+	})
+}
@@ -0,0 +1,433 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knadh/smtppool"
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/tools/email"
+	"github.com/xgfone/go-toolkit/unsafex"
+	"golang.org/x/net/idna"
+)
+
+// emailBuilder is used to build the email from the message,
+// which is shared by all the drivers in this package.
+type emailBuilder struct {
+	from      string
+	fromaddr  string   // The lower-case address of from.
+	domain    string   // The ascii domain of from, which is used by Message-ID.
+	allowfrom []string // The lower-case addresses or domains like "@example.com".
+
+	unsubscribe *unsubscriber
+
+	attachdir  string
+	attachsize int64 // The maximum total size of all the attachments.
+
+	dkim  *dkimSigner
+	smime *smimeSigner
+	pgp   *pgpSecurer
+}
+
+func newEmailBuilder(name string, config map[string]any) (b emailBuilder, err error) {
+	if b.from, _ = config["from"].(string); b.from == "" {
+		return b, errors.New("from is missing or invalid")
+	}
+
+	addr, err := netmail.ParseAddress(b.from)
+	if err != nil {
+		return b, fmt.Errorf("invalid from '%s': %w", b.from, err)
+	}
+	b.fromaddr = strings.ToLower(addr.Address)
+	if b.domain, err = idna.Lookup.ToASCII(b.fromaddr[strings.LastIndexByte(b.fromaddr, '@')+1:]); err != nil {
+		return b, fmt.Errorf("invalid from '%s': %w", b.from, err)
+	}
+
+	if b.allowfrom, err = getStrings(config, "allowfrom", nil); err != nil {
+		return
+	}
+	for i, from := range b.allowfrom {
+		if !strings.HasPrefix(from, "@") {
+			if addr, err := netmail.ParseAddress(from); err != nil || addr.Address != from {
+				return b, fmt.Errorf("invalid allowfrom '%s'", from)
+			}
+		} else if len(from) == 1 || strings.ContainsAny(from[1:], "@, ") {
+			return b, fmt.Errorf("invalid allowfrom '%s'", from)
+		}
+		b.allowfrom[i] = strings.ToLower(from)
+	}
+
+	if b.unsubscribe, err = newUnsubscriber(config); err != nil {
+		return
+	}
+
+	if b.attachdir, err = getString(config, "attachmentdir"); err != nil {
+		return
+	}
+
+	attachsize, err := getInt(config, "maxattachmentsize", 10*1024*1024)
+	if err != nil {
+		return
+	}
+	b.attachsize = int64(attachsize)
+
+	if b.dkim, err = newDKIMSigner(config); err != nil {
+		return
+	}
+
+	if b.smime, err = newSMIMESigner(config); err != nil {
+		return
+	}
+
+	if b.pgp, err = newPGPSecurer(config); err != nil {
+		return
+	} else if b.pgp != nil && b.smime != nil {
+		return b, errors.New("smime and pgp cannot be used together")
+	}
+
+	return
+}
+
+// Encode encodes the email to the raw message, which is signed or encrypted
+// by S/MIME or PGP/MIME, then signed by DKIM, if enabled.
+func (b emailBuilder) Encode(ctx context.Context, mail smtppool.Email) (data []byte, err error) {
+	if data, err = mail.Bytes(); err != nil {
+		return nil, fmt.Errorf("fail to build the email: %w", err)
+	}
+
+	switch {
+	case b.smime != nil:
+		if data, err = b.smime.Sign(data); err != nil {
+			return
+		}
+
+	case b.pgp != nil:
+		_, to, err := getEnvelope(mail)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = b.pgp.Secure(ctx, data, to); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.dkim != nil {
+		data, err = b.dkim.Sign(data)
+	}
+
+	return
+}
+
+func (b emailBuilder) Build(m driver.Message) (mail smtppool.Email, err error) {
+	msg, err := DecodeMessage(m.Content)
+	if err != nil {
+		return
+	}
+
+	if err = msg.ApplyMetadata(m.Metadata); err != nil {
+		return
+	}
+
+	mail.From = b.from
+	if msg.From != "" {
+		if err = b.checkFrom(msg.From); err != nil {
+			return
+		}
+		mail.From = msg.From
+	}
+
+	// Each address only receives the email once, even if it is in To and Cc.
+	seen := make(map[string]struct{}, 8)
+	if mail.To, err = parseAddresses([]string{m.Receiver}, seen); err != nil {
+		return mail, fmt.Errorf("receiver: %w", err)
+	} else if len(mail.To) == 0 {
+		return mail, errors.New("no receiver")
+	}
+	if mail.Cc, err = parseAddresses(msg.Cc, seen); err != nil {
+		return mail, fmt.Errorf("cc: %w", err)
+	}
+	if mail.Bcc, err = parseAddresses(msg.Bcc, seen); err != nil {
+		return mail, fmt.Errorf("bcc: %w", err)
+	}
+	if mail.ReplyTo, err = parseAddresses(msg.ReplyTo, nil); err != nil {
+		return mail, fmt.Errorf("reply-to: %w", err)
+	}
+
+	mail.Subject = msg.Subject
+
+	mail.Headers = make(textproto.MIMEHeader, len(msg.Headers)+4)
+	for key, value := range msg.Headers {
+		if err = checkHeader(key, value); err != nil {
+			return
+		}
+		mail.Headers.Set(key, value)
+	}
+
+	mail.Headers.Set(smtppool.HdrMessageID, newMessageID(b.domain, msg.MessageKey))
+	if msg.ThreadKey != "" {
+		id := threadID(b.domain, msg.ThreadKey)
+		mail.Headers.Set(hdrInReplyTo, id)
+		mail.Headers.Set(hdrReferences, id)
+	}
+	if b.unsubscribe != nil {
+		if err = b.unsubscribe.Set(mail); err != nil {
+			return
+		}
+	}
+
+	switch strings.ToLower(msg.ContentType) {
+	case "", email.ContentTypeHTML, smtppool.ContentTypeHTML:
+		if msg.Text == "" {
+			msg.Text = email.HTMLToText(msg.Content)
+		}
+		mail.HTML = unsafex.Bytes(msg.Content)
+		mail.Text = unsafex.Bytes(msg.Text)
+
+	case email.ContentTypeText, smtppool.ContentTypePlain:
+		mail.Text = unsafex.Bytes(msg.Content)
+
+	default:
+		return mail, fmt.Errorf("unsupported content type '%s'", msg.ContentType)
+	}
+
+	if len(msg.Attachments) > 0 {
+		mail.Attachments = make([]smtppool.Attachment, len(msg.Attachments))
+
+		var total int64
+		for i, a := range msg.Attachments {
+			if mail.Attachments[i], err = b.attach(a, &total); err != nil {
+				return mail, fmt.Errorf("invalid attachment #%d: %w", i, err)
+			}
+		}
+	}
+
+	return
+}
+
+// WithTo returns a copy of the email only to the recipient,
+// which has its own Message-ID and List-Unsubscribe headers.
+//
+// The Message-ID is derived from that of the email and the recipient,
+// so it is also stable if the former is derived from MessageKey.
+func (b emailBuilder) WithTo(mail smtppool.Email, to string) (smtppool.Email, error) {
+	msgid := newMessageID(b.domain, mail.Headers.Get(smtppool.HdrMessageID)+" "+strings.ToLower(to))
+	mail.To = []string{to}
+	mail.Headers = maps.Clone(mail.Headers)
+	mail.Headers.Set(smtppool.HdrMessageID, msgid)
+	if b.unsubscribe != nil {
+		if err := b.unsubscribe.Set(mail); err != nil {
+			return mail, err
+		}
+	}
+	return mail, nil
+}
+
+// parseAddresses parses the address lists, and removes the addresses in seen
+// if it is not nil, then adds the parsed addresses into it.
+func parseAddresses(lists []string, seen map[string]struct{}) (addrs []string, err error) {
+	if len(lists) == 0 {
+		return
+	}
+
+	list, err := email.ParseAddressList(strings.Join(lists, ","))
+	if err != nil {
+		return
+	}
+
+	addrs = make([]string, 0, len(list))
+	for _, addr := range list {
+		if seen != nil {
+			key := strings.ToLower(addr.Address)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+		}
+		addrs = append(addrs, addr.String())
+	}
+
+	return
+}
+
+// checkFrom checks whether the per-message sender is allowed,
+// which is the configured sender with another display name,
+// or matches an address or a domain in allowfrom.
+func (b emailBuilder) checkFrom(from string) error {
+	addr, err := netmail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid from '%s': %w", from, err)
+	}
+
+	address := strings.ToLower(addr.Address)
+	if address == b.fromaddr {
+		return nil
+	}
+
+	domain := address[strings.LastIndexByte(address, '@'):]
+	for _, allow := range b.allowfrom {
+		if allow == address || allow == domain {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("from '%s' is not allowed", from)
+}
+
+// reservedHeaders are the headers built by the driver, which cannot be overridden,
+// besides the MIME headers starting with "Content-".
+var reservedHeaders = map[string]struct{}{
+	"From":           {},
+	"Sender":         {},
+	"To":             {},
+	"Cc":             {},
+	"Bcc":            {},
+	"Reply-To":       {},
+	"Subject":        {},
+	"Date":           {},
+	"Message-Id":     {},
+	"Mime-Version":   {},
+	"Return-Path":    {},
+	"Dkim-Signature": {},
+}
+
+func checkHeader(key, value string) error {
+	if key == "" || strings.IndexFunc(key, func(r rune) bool { return r <= ' ' || r >= 0x7f || r == ':' }) >= 0 {
+		return fmt.Errorf("invalid header name '%s'", key)
+	}
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if _, ok := reservedHeaders[key]; ok || strings.HasPrefix(key, "Content-") {
+		return fmt.Errorf("header '%s' is not allowed to be set", key)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("invalid value of header '%s'", key)
+	}
+	return nil
+}
+
+func (b emailBuilder) attach(a Attachment, total *int64) (attachment smtppool.Attachment, err error) {
+	content := a.Content
+	if len(content) == 0 && a.Path != "" {
+		if content, err = b.readfile(a.Path, *total); err != nil {
+			return
+		}
+	}
+
+	if *total += int64(len(content)); b.attachsize > 0 && *total > b.attachsize {
+		return attachment, fmt.Errorf("the total size of the attachments exceeds %d bytes", b.attachsize)
+	}
+
+	name := a.Name
+	if name == "" && a.Path != "" {
+		name = filepath.Base(a.Path)
+	}
+	if name == "" {
+		return attachment, errors.New("missing the attachment name")
+	}
+
+	ctype := a.ContentType
+	if ctype == "" {
+		if ctype = mime.TypeByExtension(filepath.Ext(name)); ctype == "" {
+			ctype = smtppool.ContentTypeOctetStream
+		}
+	} else if _, _, err = mime.ParseMediaType(ctype); err != nil {
+		return attachment, fmt.Errorf("invalid content type '%s': %w", ctype, err)
+	}
+
+	disposition := "attachment"
+	if a.ContentID != "" {
+		disposition = "inline"
+	}
+	if disposition = mime.FormatMediaType(disposition, map[string]string{"filename": name}); disposition == "" {
+		return attachment, fmt.Errorf("invalid attachment name '%s'", name)
+	}
+
+	header := make(textproto.MIMEHeader, 4)
+	header.Set(smtppool.HdrContentType, ctype)
+	header.Set(smtppool.HdrContentDisposition, disposition)
+	header.Set(smtppool.HdrContentTransferEncoding, "base64")
+	if a.ContentID != "" {
+		cid := strings.TrimSuffix(strings.TrimPrefix(a.ContentID, "<"), ">")
+		if strings.ContainsAny(cid, "<>\r\n\t ") {
+			return attachment, fmt.Errorf("invalid content id '%s'", a.ContentID)
+		}
+		header.Set(smtppool.HdrContentID, "<"+cid+">")
+	}
+
+	attachment = smtppool.Attachment{
+		Filename:    name,
+		Header:      header,
+		Content:     content,
+		HTMLRelated: a.ContentID != "",
+	}
+	return
+}
+
+// readfile reads the attachment file, which must be located in attachmentdir.
+func (b emailBuilder) readfile(path string, total int64) (data []byte, err error) {
+	if b.attachdir == "" {
+		return nil, errors.New("the attachment file is not allowed")
+	}
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("the attachment file '%s' is not in attachmentdir", path)
+	}
+
+	path = filepath.Join(b.attachdir, path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	} else if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("the attachment file '%s' is not a regular file", path)
+	} else if b.attachsize > 0 && total+fi.Size() > b.attachsize {
+		return nil, fmt.Errorf("the total size of the attachments exceeds %d bytes", b.attachsize)
+	}
+
+	return os.ReadFile(path)
+}
+
+// getEnvelope returns the envelope sender and recipients of the email.
+func getEnvelope(mail smtppool.Email) (from string, to []string, err error) {
+	sender := mail.Sender
+	if sender == "" {
+		sender = mail.From
+	}
+
+	addr, err := netmail.ParseAddress(sender)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid sender '%s': %w", sender, err)
+	}
+	from = addr.Address
+
+	to = make([]string, 0, len(mail.To)+len(mail.Cc)+len(mail.Bcc))
+	for _, addrs := range [][]string{mail.To, mail.Cc, mail.Bcc} {
+		for _, s := range addrs {
+			if addr, err = netmail.ParseAddress(s); err != nil {
+				return "", nil, fmt.Errorf("invalid recipient '%s': %w", s, err)
+			}
+			to = append(to, addr.Address)
+		}
+	}
+
+	return
+}
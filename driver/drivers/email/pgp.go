@@ -0,0 +1,281 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// The PGP encryption modes.
+const (
+	pgpEncryptNone     = "none"
+	pgpEncryptOptional = "optional"
+	pgpEncryptRequired = "required"
+)
+
+var pgpConfig = &packet.Config{DefaultHash: crypto.SHA256}
+
+// pgpLookup is used to look up the armored PGP public key of the recipient
+// address, which returns ("", nil) if there is no key.
+type pgpLookup = func(ctx context.Context, address string) (armoredKey string, err error)
+
+// pgpSecurer signs and/or encrypts the email by PGP/MIME, see RFC 3156.
+type pgpSecurer struct {
+	signer  *openpgp.Entity
+	keys    map[string]*openpgp.Entity // The recipient keys indexed by the lower-case address.
+	lookup  pgpLookup                  // Called only if the key is not in keys.
+	encrypt string
+}
+
+// newPGPSecurer builds the PGP/MIME securer from the config.
+//
+// Return nil if neither the signing key nor the encryption is configured.
+func newPGPSecurer(config map[string]any) (s *pgpSecurer, err error) {
+	s = new(pgpSecurer)
+	if s.encrypt, err = getString(config, "pgpencrypt"); err != nil {
+		return
+	}
+
+	switch s.encrypt = strings.ToLower(s.encrypt); s.encrypt {
+	case "":
+		s.encrypt = pgpEncryptNone
+	case pgpEncryptNone, pgpEncryptOptional, pgpEncryptRequired:
+	default:
+		return nil, fmt.Errorf("unsupported pgpencrypt '%s'", s.encrypt)
+	}
+
+	if s.signer, err = loadPGPSigner(config); err != nil {
+		return nil, err
+	}
+
+	var keys map[string]string
+	switch _keys := config["pgpkeys"].(type) {
+	case nil:
+	case map[string]string:
+		keys = _keys
+
+	case map[string]any:
+		keys = make(map[string]string, len(_keys))
+		for addr, key := range _keys {
+			armored, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("pgpkeys['%s'] expects a string, but got %T", addr, key)
+			}
+			keys[addr] = armored
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported pgpkeys type %T", _keys)
+	}
+
+	if len(keys) > 0 {
+		s.keys = make(map[string]*openpgp.Entity, len(keys))
+		for addr, armored := range keys {
+			if s.keys[strings.ToLower(addr)], err = parsePGPPublicKey(armored); err != nil {
+				return nil, fmt.Errorf("invalid pgpkeys['%s']: %w", addr, err)
+			}
+		}
+	}
+
+	switch lookup := config["pgplookup"].(type) {
+	case nil:
+	case pgpLookup:
+		s.lookup = lookup
+	default:
+		return nil, fmt.Errorf("pgplookup expects a func(context.Context, string) (string, error), but got %T", lookup)
+	}
+
+	if s.signer == nil && s.encrypt == pgpEncryptNone {
+		return nil, nil
+	}
+	return
+}
+
+func loadPGPSigner(config map[string]any) (signer *openpgp.Entity, err error) {
+	key, err := getString(config, "pgpkey")
+	if err != nil {
+		return
+	} else if key == "" {
+		keyfile, err := getString(config, "pgpkeyfile")
+		if err != nil || keyfile == "" {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pgpkeyfile: %w", err)
+		}
+		key = string(data)
+	}
+
+	passphrase, err := getString(config, "pgppassphrase")
+	if err != nil {
+		return
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pgp key: %w", err)
+	}
+
+	for _, entity := range entities {
+		if entity.PrivateKey != nil {
+			signer = entity
+			break
+		}
+	}
+
+	switch {
+	case signer == nil:
+		return nil, errors.New("invalid pgp key: no private key")
+	case passphrase != "":
+		if err = signer.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("invalid pgp key: %w", err)
+		}
+	}
+
+	if _, ok := signer.SigningKey(time.Now()); !ok {
+		return nil, errors.New("invalid pgp key: no valid signing key")
+	}
+
+	return
+}
+
+func parsePGPPublicKey(key string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, entity := range entities {
+		if _, ok := entity.EncryptionKey(now); ok {
+			return entity, nil
+		}
+	}
+
+	return nil, errors.New("no valid encryption key")
+}
+
+// Secure signs and/or encrypts the email message to the recipients,
+// and returns the new one as multipart/signed or multipart/encrypted.
+func (s *pgpSecurer) Secure(ctx context.Context, msg []byte, recipients []string) ([]byte, error) {
+	var keys []*openpgp.Entity
+	if s.encrypt != pgpEncryptNone {
+		var err error
+		if keys, err = s.lookupKeys(ctx, recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	headers, entity := splitEntity(msg)
+	if len(keys) > 0 {
+		return s.encryptEntity(headers, entity, keys)
+	}
+	if s.signer != nil {
+		return s.signEntity(headers, entity)
+	}
+	return msg, nil
+}
+
+// lookupKeys returns the keys of all the recipients, or nil if the encryption
+// is optional and some recipient has no key.
+func (s *pgpSecurer) lookupKeys(ctx context.Context, recipients []string) ([]*openpgp.Entity, error) {
+	keys := make([]*openpgp.Entity, 0, len(recipients))
+
+	var missings []string
+	for _, addr := range recipients {
+		key, ok := s.keys[strings.ToLower(addr)]
+		if !ok && s.lookup != nil {
+			armored, err := s.lookup(ctx, addr)
+			if err != nil {
+				return nil, fmt.Errorf("pgp: fail to look up the key of '%s': %w", addr, err)
+			} else if armored != "" {
+				if key, err = parsePGPPublicKey(armored); err != nil {
+					return nil, fmt.Errorf("pgp: invalid key of '%s': %w", addr, err)
+				}
+			}
+		}
+
+		if key == nil {
+			missings = append(missings, addr)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+
+	switch {
+	case len(missings) == 0:
+		return keys, nil
+	case s.encrypt == pgpEncryptRequired:
+		return nil, fmt.Errorf("pgp: no key for the recipients: %s", strings.Join(missings, ", "))
+	default:
+		return nil, nil
+	}
+}
+
+func (s *pgpSecurer) signEntity(headers, entity []byte) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, s.signer, bytes.NewReader(entity), pgpConfig); err != nil {
+		return nil, fmt.Errorf("pgp: fail to sign: %w", err)
+	}
+
+	w := newMultipartWriter(headers, `multipart/signed; protocol="application/pgp-signature"; micalg=pgp-sha256`)
+	w.WritePart(entity)
+	w.WritePart(append([]byte("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n"+
+		"Content-Disposition: attachment; filename=\"signature.asc\"\r\n\r\n"), toCRLF(signature.Bytes())...))
+	return w.Close(), nil
+}
+
+func (s *pgpSecurer) encryptEntity(headers, entity []byte, keys []*openpgp.Entity) ([]byte, error) {
+	var ciphertext bytes.Buffer
+	armored, err := armor.Encode(&ciphertext, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, fmt.Errorf("pgp: fail to encrypt: %w", err)
+	}
+
+	plaintext, err := openpgp.Encrypt(armored, keys, s.signer, nil, pgpConfig)
+	if err != nil {
+		return nil, fmt.Errorf("pgp: fail to encrypt: %w", err)
+	}
+
+	if _, err = plaintext.Write(entity); err == nil {
+		if err = plaintext.Close(); err == nil {
+			err = armored.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pgp: fail to encrypt: %w", err)
+	}
+
+	w := newMultipartWriter(headers, `multipart/encrypted; protocol="application/pgp-encrypted"`)
+	w.WritePart([]byte("Content-Type: application/pgp-encrypted\r\n" +
+		"Content-Description: PGP/MIME version identification\r\n\r\nVersion: 1\r\n"))
+	w.WritePart(append([]byte("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n"+
+		"Content-Description: OpenPGP encrypted message\r\n"+
+		"Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n"), toCRLF(ciphertext.Bytes())...))
+	return w.Close(), nil
+}
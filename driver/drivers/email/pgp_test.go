@@ -0,0 +1,175 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const testMessage = "From: <from@example.com>\r\nTo: <a@example.com>\r\nSubject: test\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n\r\nhello\r\n"
+
+// splitMultipart returns the media type and the raw parts of the multipart message.
+func splitMultipart(t *testing.T, msg []byte) (mediatype string, parts [][]byte) {
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediatype, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(m.Body)
+	delimiter := "--" + params["boundary"]
+	body, _, _ = bytes.Cut(body, []byte("\r\n"+delimiter+"--\r\n"))
+	for _, part := range bytes.Split(body, []byte(delimiter+"\r\n"))[1:] {
+		parts = append(parts, bytes.TrimSuffix(part, []byte("\r\n")))
+	}
+	return
+}
+
+func newTestPGPEntity(t *testing.T, email string) (entity *openpgp.Entity, armoredPublic, armoredPrivate string) {
+	entity, err := openpgp.NewEntity("test", "", email, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var public, private bytes.Buffer
+	w, _ := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	_ = entity.Serialize(w)
+	_ = w.Close()
+
+	w, _ = armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	_ = entity.SerializePrivate(w, nil)
+	_ = w.Close()
+
+	return entity, public.String(), private.String()
+}
+
+func TestPGPSecurer(t *testing.T) {
+	signer, _, signerkey := newTestPGPEntity(t, "from@example.com")
+	recipient1, publickey1, _ := newTestPGPEntity(t, "a@example.com")
+	recipient2, publickey2, _ := newTestPGPEntity(t, "b@example.com")
+
+	var lookups []string
+	config := map[string]any{
+		"pgpkey":     signerkey,
+		"pgpencrypt": "optional",
+		"pgpkeys":    map[string]string{"A@example.com": publickey1},
+		"pgplookup": func(_ context.Context, addr string) (string, error) {
+			lookups = append(lookups, addr)
+			if addr == "b@example.com" {
+				return publickey2, nil
+			}
+			return "", nil
+		},
+	}
+
+	s, err := newPGPSecurer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, entity := splitEntity([]byte(testMessage))
+
+	// Encrypt and sign.
+	msg, err := s.Secure(context.Background(), []byte(testMessage), []string{"a@example.com", "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediatype, parts := splitMultipart(t, msg)
+	if mediatype != "multipart/encrypted" || len(parts) != 2 {
+		t.Fatalf("expect multipart/encrypted with 2 parts, but got %s with %d parts", mediatype, len(parts))
+	}
+	if strings.Join(lookups, ",") != "b@example.com" {
+		t.Errorf("unexpected the looked up addresses %v", lookups)
+	}
+
+	_, ciphertext, _ := bytes.Cut(parts[1], []byte("\r\n\r\n"))
+	for _, recipient := range []*openpgp.Entity{recipient1, recipient2} {
+		block, err := armor.Decode(bytes.NewReader(ciphertext))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{recipient, signer}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plaintext, err := io.ReadAll(md.UnverifiedBody)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(plaintext, entity) {
+			t.Errorf("expect the plaintext %q, but got %q", entity, plaintext)
+		}
+		if !md.IsSigned || md.SignatureError != nil {
+			t.Errorf("expect a valid signature, but got signed=%v, err=%v", md.IsSigned, md.SignatureError)
+		}
+	}
+
+	// Only sign if some recipient has no key.
+	msg, err = s.Secure(context.Background(), []byte(testMessage), []string{"a@example.com", "c@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediatype, parts = splitMultipart(t, msg)
+	if mediatype != "multipart/signed" || len(parts) != 2 {
+		t.Fatalf("expect multipart/signed with 2 parts, but got %s with %d parts", mediatype, len(parts))
+	} else if !bytes.Equal(parts[0], entity) {
+		t.Errorf("expect the signed entity %q, but got %q", entity, parts[0])
+	}
+
+	_, signature, _ := bytes.Cut(parts[1], []byte("\r\n\r\n"))
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{signer},
+		bytes.NewReader(parts[0]), bytes.NewReader(signature), nil)
+	if err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+
+	// Fail to encrypt if some recipient has no key.
+	config["pgpencrypt"] = "required"
+	if s, err = newPGPSecurer(config); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Secure(context.Background(), []byte(testMessage), []string{"a@example.com", "c@example.com"})
+	if err == nil || !strings.Contains(err.Error(), "c@example.com") {
+		t.Errorf("expect an error about c@example.com, but got %v", err)
+	}
+}
+
+func TestPGPSecurerConfig(t *testing.T) {
+	for _, config := range []map[string]any{
+		{"pgpencrypt": "required", "pgpkeys": map[string]int{"a@example.com": 1}},
+		{"pgpencrypt": "required", "pgpkeys": map[string]any{"a@example.com": 1}},
+		{"pgpencrypt": "required", "pgplookup": "lookup"},
+	} {
+		if _, err := newPGPSecurer(config); err == nil {
+			t.Errorf("expect an error for %v, but got nil", config)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errPoolClosed = errors.New("smtp pool is closed")
+
+// connError is the error to connect to or authenticate with the smtp server,
+// which means that the message has not been sent.
+type connError struct {
+	addr string
+	err  error
+}
+
+func (e connError) Unwrap() error { return e.err }
+func (e connError) Error() string {
+	return fmt.Sprintf("fail to connect to the smtp server '%s': %s", e.addr, e.err)
+}
+
+// dataError is the error after DATA is accepted, but not the reply error
+// of the smtp server, such as the connection is broken before the final reply,
+// which means that the message may have been delivered. So it is not temporary
+// to avoid delivering the message twice by the retry.
+type dataError struct{ err error }
+
+func (e dataError) Unwrap() error   { return e.err }
+func (e dataError) Error() string   { return e.err.Error() }
+func (e dataError) Temporary() bool { return false }
+
+type poolOption struct {
+	Host      string
+	Port      int
+	TLSMode   string
+	TLSConfig *tls.Config
+	Auth      smtp.Auth
+
+	MaxConns    int
+	IdleTimeout time.Duration
+	WaitTimeout time.Duration // Also used as the timeout to dial a new connection.
+}
+
+// smtpPool is a pool of the reusable smtp connections, which sends
+// the raw message built in advance so that it can be signed or encrypted.
+//
+// Different from smtppool.Pool, it supports the plain connection without
+// STARTTLS, and respects the deadline and cancellation of the context.
+type smtpPool struct {
+	opt   poolOption
+	addr  string
+	slots chan struct{}  // Each live connection holds a slot.
+	idles chan *smtpConn // The idle connections.
+	stop  chan struct{}
+	once  sync.Once
+	done  atomic.Bool
+}
+
+type smtpConn struct {
+	conn   net.Conn
+	client *smtp.Client
+	active time.Time
+}
+
+func newSMTPPool(opt poolOption) (*smtpPool, error) {
+	if opt.MaxConns < 1 {
+		return nil, fmt.Errorf("invalid maxconnnum %d", opt.MaxConns)
+	}
+	if opt.WaitTimeout <= 0 {
+		opt.WaitTimeout = 3 * time.Second
+	}
+	if opt.TLSConfig == nil {
+		opt.TLSConfig = &tls.Config{ServerName: opt.Host}
+	}
+
+	p := &smtpPool{
+		opt:   opt,
+		addr:  net.JoinHostPort(opt.Host, strconv.FormatInt(int64(opt.Port), 10)),
+		slots: make(chan struct{}, opt.MaxConns),
+		idles: make(chan *smtpConn, opt.MaxConns),
+		stop:  make(chan struct{}),
+	}
+
+	if opt.IdleTimeout > 0 {
+		go p.sweep(max(opt.IdleTimeout/2, time.Second))
+	}
+
+	return p, nil
+}
+
+// Close closes the pool and all the idle connections.
+func (p *smtpPool) Close() {
+	p.once.Do(func() {
+		p.done.Store(true)
+		close(p.stop)
+		for {
+			select {
+			case c := <-p.idles:
+				p.quit(c)
+			default:
+				return
+			}
+		}
+	})
+}
+
+// Send sends the raw message msg from the envelope sender from to the recipients to.
+func (p *smtpPool) Send(ctx context.Context, from string, to []string, msg []byte) (err error) {
+	// Retry once with a new connection if the reused one has been broken,
+	// for example, it has been closed by the server because of idle.
+	//
+	// Never retry after DATA is accepted, because the server may have
+	// accepted the message even if it fails to write the message or
+	// read the final reply, and retrying may deliver it twice.
+	for range 2 {
+		var c *smtpConn
+		var reused, indata bool
+		if c, reused, err = p.get(ctx); err != nil {
+			return
+		}
+
+		indata, err = p.send(ctx, c, from, to, msg)
+		if indata && err != nil && !isProtoError(err) {
+			return dataError{err: err}
+		}
+		if err == nil || indata || !reused || ctx.Err() != nil || isProtoError(err) {
+			return
+		}
+	}
+	return
+}
+
+// isProtoError reports whether err is the reply error of the smtp server.
+func isProtoError(err error) bool {
+	var perr *textproto.Error
+	return errors.As(err, &perr)
+}
+
+// send sends the message by the connection, and reports whether DATA
+// has been accepted by the server.
+func (p *smtpPool) send(ctx context.Context, c *smtpConn, from string, to []string, msg []byte) (indata bool, err error) {
+	defer func() { p.put(c, err) }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = c.conn.SetDeadline(time.Now()) })
+	defer func() {
+		if !stop() && err != nil {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		_ = c.conn.SetDeadline(time.Time{})
+	}()
+
+	c.active = time.Now()
+	if err = c.client.Mail(from); err != nil {
+		return
+	}
+
+	for _, addr := range to {
+		if err = c.client.Rcpt(addr); err != nil {
+			return
+		}
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return
+	}
+
+	indata = true
+	if _, err = w.Write(msg); err != nil {
+		_ = w.Close()
+		return
+	}
+
+	err = w.Close()
+	return
+}
+
+func (p *smtpPool) get(ctx context.Context) (c *smtpConn, reused bool, err error) {
+	if p.done.Load() {
+		return nil, false, errPoolClosed
+	}
+
+	select {
+	case c = <-p.idles:
+		return c, true, nil
+	default:
+	}
+
+	timer := time.NewTimer(p.opt.WaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case c = <-p.idles:
+		return c, true, nil
+
+	case p.slots <- struct{}{}:
+		if c, err = p.dial(ctx); err != nil {
+			err = connError{addr: p.addr, err: err}
+			<-p.slots
+		}
+		return
+
+	case <-timer.C:
+		return nil, false, errors.New("timed out waiting for a free smtp connection")
+
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+
+	case <-p.stop:
+		return nil, false, errPoolClosed
+	}
+}
+
+func (p *smtpPool) put(c *smtpConn, err error) {
+	if err != nil {
+		// Only the smtp protocol error does not break the connection,
+		// unless the context is done during the command.
+		if !isProtoError(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			p.close(c)
+			return
+		}
+
+		// Avoid to hang on RSET forever by the stuck server.
+		_ = c.conn.SetDeadline(time.Now().Add(p.opt.WaitTimeout))
+		err = c.client.Reset()
+		_ = c.conn.SetDeadline(time.Time{})
+		if err != nil {
+			p.close(c)
+			return
+		}
+	}
+
+	if p.done.Load() {
+		p.quit(c)
+		return
+	}
+
+	c.active = time.Now()
+	p.idles <- c // The capacity is the same as slots, so it never blocks.
+}
+
+func (p *smtpPool) close(c *smtpConn) {
+	_ = c.client.Close()
+	<-p.slots
+}
+
+func (p *smtpPool) quit(c *smtpConn) {
+	_ = c.conn.SetDeadline(time.Now().Add(time.Second))
+	_ = c.client.Quit()
+	p.close(c)
+}
+
+func (p *smtpPool) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for range len(p.idles) {
+			var c *smtpConn
+			select {
+			case c = <-p.idles:
+			default:
+			}
+
+			if c == nil {
+				break
+			} else if time.Since(c.active) > p.opt.IdleTimeout {
+				p.quit(c)
+			} else {
+				p.idles <- c
+			}
+		}
+	}
+}
+
+func (p *smtpPool) dial(ctx context.Context) (c *smtpConn, err error) {
+	ctx, cancel := context.WithTimeout(ctx, p.opt.WaitTimeout)
+	defer cancel()
+
+	// Refresh the credential, such as the oauth2 token, before it expires.
+	if r, ok := p.opt.Auth.(interface{ Refresh(context.Context) error }); ok {
+		if err = r.Refresh(ctx); err != nil {
+			return
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return
+	}
+
+	if p.opt.TLSMode == tlsImplicit {
+		tlsconn := tls.Client(conn, p.opt.TLSConfig)
+		if err = tlsconn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return
+		}
+		conn = tlsconn
+	}
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, p.opt.Host)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = client.Close()
+		} else {
+			_ = conn.SetDeadline(time.Time{})
+		}
+	}()
+
+	if p.opt.TLSMode == tlsStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err = client.StartTLS(p.opt.TLSConfig); err != nil {
+			return
+		}
+	}
+
+	if p.opt.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return nil, errors.New("smtp server does not support AUTH")
+		}
+		if err = client.Auth(p.opt.Auth); err != nil {
+			return
+		}
+	}
+
+	return &smtpConn{conn: conn, client: client}, nil
+}
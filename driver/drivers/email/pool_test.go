@@ -0,0 +1,364 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver/middleware/retry"
+)
+
+// fakeSMTPServer is a fake smtp server for test.
+//
+// The recipient containing "dropdata" makes it close the connection
+// after receiving the message without the reply, and the recipients
+// containing "reject4" and "reject5" are rejected with 450 and 550.
+type fakeSMTPServer struct {
+	tls      *tls.Config
+	implicit bool
+	starttls bool
+	delay    time.Duration // The delay to reply DATA.
+
+	username string
+	password string
+	token    string
+
+	ln    net.Listener
+	lock  sync.Mutex
+	conns []net.Conn
+	dials int
+	mails []string
+	auths []string
+
+	active    int // The number of the messages being received.
+	maxActive int
+}
+
+func newFakeSMTPServer(t *testing.T, s *fakeSMTPServer) (host string, port int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.implicit {
+		ln = tls.NewListener(ln, s.tls)
+	}
+
+	s.ln = ln
+	t.Cleanup(func() { _ = ln.Close(); s.drop() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			s.lock.Lock()
+			s.dials++
+			s.conns = append(s.conns, conn)
+			s.lock.Unlock()
+			go s.serve(conn)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// drop closes all the connections.
+func (s *fakeSMTPServer) drop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeSMTPServer) stats() (dials int, mails, auths []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.dials, append([]string(nil), s.mails...), append([]string(nil), s.auths...)
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	read := func() string {
+		line, _ := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+	decode := func(s string) string {
+		data, _ := base64.StdEncoding.DecodeString(s)
+		return string(data)
+	}
+
+	var dropdata bool
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		fields := strings.Fields(line)
+		switch cmd := strings.ToUpper(fields[0]); cmd {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			if _, ok := conn.(*tls.Conn); s.starttls && !ok {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN LOGIN CRAM-MD5 XOAUTH2")
+
+		case "STARTTLS":
+			reply("220 ready")
+			conn = tls.Server(conn, s.tls)
+			r = bufio.NewReader(conn)
+
+		case "AUTH":
+			var ok bool
+			switch mechanism := strings.ToUpper(fields[1]); mechanism {
+			case "PLAIN":
+				ok = decode(fields[2]) == "\x00"+s.username+"\x00"+s.password
+
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				username := decode(read())
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				ok = username == s.username && decode(read()) == s.password
+
+			case "CRAM-MD5":
+				challenge := "<123.456@localhost>"
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte(challenge)))
+				h := hmac.New(md5.New, []byte(s.password))
+				h.Write([]byte(challenge))
+				ok = decode(read()) == s.username+" "+hex.EncodeToString(h.Sum(nil))
+
+			case "XOAUTH2":
+				ok = decode(fields[2]) == "user="+s.username+"\x01auth=Bearer "+s.token+"\x01\x01"
+			}
+
+			if ok {
+				s.lock.Lock()
+				s.auths = append(s.auths, strings.ToUpper(fields[1]))
+				s.lock.Unlock()
+				reply("235 ok")
+			} else {
+				reply("535 authentication failed")
+			}
+
+		case "MAIL", "RSET", "NOOP":
+			reply("250 ok")
+
+		case "RCPT":
+			switch {
+			case strings.Contains(line, "reject4"):
+				reply("450 mailbox busy")
+			case strings.Contains(line, "reject5"):
+				reply("550 no such user")
+			default:
+				dropdata = strings.Contains(line, "dropdata")
+				reply("250 ok")
+			}
+
+		case "DATA":
+			s.lock.Lock()
+			s.active++
+			s.maxActive = max(s.maxActive, s.active)
+			s.lock.Unlock()
+
+			time.Sleep(s.delay)
+			s.lock.Lock()
+			s.active--
+			s.lock.Unlock()
+
+			reply("354 go ahead")
+			var b strings.Builder
+			for line := read(); line != "."; line = read() {
+				b.WriteString(line)
+				b.WriteString("\r\n")
+			}
+
+			s.lock.Lock()
+			s.mails = append(s.mails, b.String())
+			s.lock.Unlock()
+			if dropdata {
+				return
+			}
+			reply("250 queued")
+
+		case "QUIT":
+			reply("221 bye")
+			return
+
+		default:
+			reply("500 unknown command")
+		}
+	}
+}
+
+func newTestCert(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{ServerName: "127.0.0.1", RootCAs: roots}
+	return
+}
+
+func TestSMTPPoolReuse(t *testing.T) {
+	s := &fakeSMTPServer{}
+	host, port := newFakeSMTPServer(t, s)
+
+	p, err := newSMTPPool(poolOption{Host: host, Port: port, TLSMode: tlsNone, MaxConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	send := func(to string) error {
+		return p.Send(context.Background(), "from@example.com", []string{to}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	}
+
+	for i := range 3 {
+		if err := send("to@example.com"); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	if dials, mails, _ := s.stats(); dials != 1 || len(mails) != 3 {
+		t.Errorf("expect 1 connection and 3 mails, but got %d and %d", dials, len(mails))
+	}
+
+	// The idle connection is closed by the server, and recover with a new one.
+	s.drop()
+	if err := send("to@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if dials, mails, _ := s.stats(); dials != 2 || len(mails) != 4 {
+		t.Errorf("expect 2 connections and 4 mails, but got %d and %d", dials, len(mails))
+	}
+
+	// Do not retry after DATA is accepted, which may deliver it twice.
+	if err := send("dropdata@example.com"); err == nil {
+		t.Errorf("expect an error, but got nil")
+	} else if retry.IsTransient(err) {
+		t.Errorf("expect the error after DATA is not transient: %v", err)
+	}
+	if dials, mails, _ := s.stats(); dials != 2 || len(mails) != 5 {
+		t.Errorf("expect 2 connections and 5 mails, but got %d and %d", dials, len(mails))
+	}
+}
+
+func TestSMTPPoolAuthTLS(t *testing.T) {
+	servertls, clienttls := newTestCert(t)
+	for _, c := range []struct {
+		auth    string
+		tlsmode string
+	}{
+		{auth: "plain", tlsmode: tlsNone},
+		{auth: "login", tlsmode: tlsStartTLS},
+		{auth: "cram-md5", tlsmode: tlsImplicit},
+		{auth: "xoauth2", tlsmode: tlsStartTLS},
+		{auth: "plain", tlsmode: tlsImplicit},
+	} {
+		t.Run(c.auth+"/"+c.tlsmode, func(t *testing.T) {
+			s := &fakeSMTPServer{
+				tls:      servertls,
+				implicit: c.tlsmode == tlsImplicit,
+				starttls: c.tlsmode == tlsStartTLS,
+				username: "user",
+				password: "pass",
+				token:    "token",
+			}
+			host, port := newFakeSMTPServer(t, s)
+
+			config := map[string]any{"auth": c.auth, "username": "user", "password": "pass", "oauth2token": "token"}
+			auth, err := newAuth(config, host, c.tlsmode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opt := poolOption{Host: host, Port: port, TLSMode: c.tlsmode, TLSConfig: clienttls, Auth: auth, MaxConns: 1}
+			p, err := newSMTPPool(opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			err = p.Send(context.Background(), "from@example.com", []string{"to@example.com"}, []byte("\r\nbody\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect := strings.ToUpper(c.auth)
+			if _, mails, auths := s.stats(); len(mails) != 1 || len(auths) != 1 || auths[0] != expect {
+				t.Errorf("expect 1 mail authenticated by %s, but got %d mails and auths %v", expect, len(mails), auths)
+			}
+		})
+	}
+
+	s := &fakeSMTPServer{username: "user", password: "pass"}
+	host, port := newFakeSMTPServer(t, s)
+	auth, _ := newAuth(map[string]any{"username": "user", "password": "wrong"}, host, tlsNone)
+	p, _ := newSMTPPool(poolOption{Host: host, Port: port, TLSMode: tlsNone, Auth: auth, MaxConns: 1})
+	defer p.Close()
+
+	err := p.Send(context.Background(), "from@example.com", []string{"to@example.com"}, []byte("\r\nbody\r\n"))
+	if _, ok := err.(connError); !ok || !strings.Contains(err.Error(), "535") {
+		t.Errorf("expect a connection error with 535, but got %v", err)
+	}
+}
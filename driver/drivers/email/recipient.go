@@ -0,0 +1,174 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/knadh/smtppool"
+)
+
+// The send modes of the email driver.
+const (
+	sendModeBatch      = "batch"
+	sendModeIndividual = "individual"
+)
+
+// RecipientResult is the result to send the email to a recipient.
+type RecipientResult struct {
+	// Address is the recipient formatted by RFC 5322,
+	// such as "<user@example.com>" or "Name <user@example.com>".
+	Address string
+
+	// Code is the SMTP reply code, such as 550, when the recipient
+	// is rejected by the mail server. It is 0 for other errors.
+	Code int
+
+	// Err is the error to send the email, which is nil if accepted.
+	Err error
+}
+
+// RecipientsError is returned by the email driver with the individual send mode
+// when the email fails to be sent to some recipients.
+type RecipientsError struct {
+	Results []RecipientResult // The results of all the recipients.
+}
+
+// Failed returns the addresses of the recipients that the email fails to be sent to,
+// which can be joined by the comma as the receiver to retry.
+func (e RecipientsError) Failed() []string {
+	failed := make([]string, 0, len(e.Results))
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, r.Address)
+		}
+	}
+	return failed
+}
+
+// Unwrap returns the errors of the failed recipients.
+func (e RecipientsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Results))
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// Temporary reports whether the error is temporary, that's, the email is not
+// sent to any recipient and all the failures are temporary, such as the SMTP
+// reply code 4xx or the connection error, so that it is safe to resend
+// the email to all the recipients. Or, resend it to Failed() instead.
+func (e RecipientsError) Temporary() bool {
+	for _, r := range e.Results {
+		switch {
+		case r.Err == nil, r.Code >= 500:
+			return false
+		case r.Code >= 400:
+		default:
+			var neterr net.Error
+			var connerr connError
+			var dataerr dataError
+			if errors.As(r.Err, &dataerr) || (!errors.As(r.Err, &neterr) && !errors.As(r.Err, &connerr)) {
+				return false
+			}
+		}
+	}
+	return len(e.Results) > 0
+}
+
+// Error implements the interface error.
+func (e RecipientsError) Error() string {
+	var b strings.Builder
+	b.Grow(64 * len(e.Results))
+
+	var failed int
+	for _, r := range e.Results {
+		if r.Err == nil {
+			continue
+		}
+
+		if failed++; failed > 1 {
+			b.WriteString("; ")
+		}
+		b.WriteString(r.Address)
+		b.WriteString(": ")
+		b.WriteString(r.Err.Error())
+	}
+
+	return fmt.Sprintf("fail to send the email to %d of %d recipients: %s", failed, len(e.Results), b.String())
+}
+
+// sendIndividually sends the email to each recipient in To one by one,
+// or concurrently if concurrency is greater than 1.
+func (d driverImpl) sendIndividually(c context.Context, mail smtppool.Email) error {
+	if len(mail.Cc) > 0 || len(mail.Bcc) > 0 {
+		return errors.New("cc and bcc are not supported by the individual send mode")
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.concurrency)
+	results := make([]RecipientResult, len(mail.To))
+	for i, to := range mail.To {
+		results[i].Address = strings.TrimSpace(to)
+
+		select {
+		case sem <- struct{}{}:
+		case <-c.Done():
+			results[i].Err = c.Err()
+			continue
+		}
+
+		mail, err := d.builder.WithTo(mail, to)
+		if err != nil {
+			<-sem
+			results[i].Err = err
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *RecipientResult, mail smtppool.Email) {
+			defer func() { <-sem; wg.Done() }()
+			defer func() { // The recovery middleware cannot recover the panic in the goroutine.
+				if r := recover(); r != nil {
+					result.Err = fmt.Errorf("panic when sending the email: %v", r)
+				}
+			}()
+
+			if result.Err = d.send(c, mail); result.Err != nil {
+				var perr *textproto.Error
+				if errors.As(result.Err, &perr) {
+					result.Code = perr.Code
+				}
+			}
+		}(&results[i], mail)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if r.Err != nil {
+			return RecipientsError{Results: results}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestSendIndividually(t *testing.T) {
+	s := &fakeSMTPServer{delay: 20 * time.Millisecond}
+	host, port := newFakeSMTPServer(t, s)
+
+	d, err := New("email", map[string]any{
+		"addr":        net.JoinHostPort(host, strconv.Itoa(port)),
+		"tls":         tlsNone,
+		"from":        "from@example.com",
+		"sendmode":    "individual",
+		"concurrency": 2,
+		"pgpencrypt":  "optional",
+		"pgplookup": func(_ context.Context, addr string) (string, error) {
+			if strings.HasPrefix(addr, "panic@") {
+				panic("lookup")
+			}
+			return "", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	send := func(receiver string) error {
+		m := driver.NewMessage("", "", receiver, Message{Subject: "subject", Content: "content"}, nil)
+		return d.Send(context.Background(), m)
+	}
+
+	receiver := "a@example.com, reject4@example.com, b@example.com, c@example.com," +
+		"reject5@example.com, d@example.com, e@example.com, panic@example.com"
+	err = send(receiver)
+
+	var rerr RecipientsError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expect a RecipientsError, but got %v", err)
+	}
+
+	expect := []string{"<reject4@example.com>", "<reject5@example.com>", "<panic@example.com>"}
+	if failed := rerr.Failed(); !reflect.DeepEqual(failed, expect) {
+		t.Errorf("expect the failed recipients %v, but got %v", expect, failed)
+	}
+	if rerr.Temporary() {
+		t.Errorf("expect the error is not temporary")
+	}
+	if errs := rerr.Unwrap(); len(errs) != 3 {
+		t.Errorf("expect 3 errors, but got %d", len(errs))
+	}
+
+	var perr *textproto.Error
+	if !errors.As(err, &perr) || (perr.Code != 450 && perr.Code != 550) {
+		t.Errorf("expect a textproto error, but got %v", perr)
+	}
+	for i, code := range []int{0, 450, 0, 0, 550, 0, 0, 0} {
+		if r := rerr.Results[i]; r.Code != code {
+			t.Errorf("%s: expect code %d, but got %d", r.Address, code, r.Code)
+		}
+	}
+	if r := rerr.Results[7]; r.Err == nil || !strings.Contains(r.Err.Error(), "panic") {
+		t.Errorf("expect a panic error, but got %v", r.Err)
+	}
+
+	s.lock.Lock()
+	mails, maxActive := len(s.mails), s.maxActive
+	s.lock.Unlock()
+	if mails != 5 {
+		t.Errorf("expect 5 mails, but got %d", mails)
+	}
+	if maxActive > 2 {
+		t.Errorf("expect at most 2 mails sent concurrently, but got %d", maxActive)
+	}
+
+	// All the failures are temporary.
+	if err = send("reject4@example.com, reject4-1@example.com"); !errors.As(err, &rerr) {
+		t.Errorf("expect a RecipientsError, but got %v", err)
+	} else if !rerr.Temporary() {
+		t.Errorf("expect the error is temporary: %v", err)
+	}
+
+	// The message may have been delivered after DATA.
+	if err = send("reject4@example.com, dropdata@example.com"); !errors.As(err, &rerr) {
+		t.Errorf("expect a RecipientsError, but got %v", err)
+	} else if rerr.Temporary() {
+		t.Errorf("expect the error is not temporary: %v", err)
+	}
+
+	if err = send("a@example.com, b@example.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+// splitEntity splits the email message into the message headers
+// and the MIME entity, which consists of the Content-* headers and the body.
+//
+// Both of them use CRLF as the line ending.
+func splitEntity(msg []byte) (headers, entity []byte) {
+	msg = toCRLF(msg)
+
+	var header, body []byte
+	if index := bytes.Index(msg, []byte("\r\n\r\n")); index < 0 {
+		header = msg
+	} else {
+		header, body = msg[:index+2], msg[index+4:]
+	}
+
+	headers = make([]byte, 0, len(header))
+	entity = make([]byte, 0, len(header)/2+len(body)+2)
+	for _, field := range splitHeaderFields(header) {
+		if len(field) > 8 && strings.EqualFold(field[:8], "Content-") {
+			entity = append(entity, field...)
+		} else {
+			headers = append(headers, field...)
+		}
+	}
+
+	entity = append(entity, "\r\n"...)
+	entity = append(entity, body...)
+	return
+}
+
+// multipartWriter writes the multipart body whose parts are written in advance.
+type multipartWriter struct {
+	bytes.Buffer
+	boundary string
+}
+
+func newMultipartWriter(headers []byte, ctype string) *multipartWriter {
+	w := &multipartWriter{boundary: multipart.NewWriter(io.Discard).Boundary()}
+	w.Grow(len(headers) + 4096)
+	w.Write(headers)
+	w.WriteString("Content-Type: ")
+	w.WriteString(strings.ReplaceAll(ctype, "; ", ";\r\n "))
+	w.WriteString(";\r\n boundary=\"")
+	w.WriteString(w.boundary)
+	w.WriteString("\"\r\n\r\n")
+	return w
+}
+
+// WritePart writes the raw part, which contains the part headers and body.
+func (w *multipartWriter) WritePart(part []byte) {
+	w.WriteString("--")
+	w.WriteString(w.boundary)
+	w.WriteString("\r\n")
+	w.Write(part)
+	w.WriteString("\r\n")
+}
+
+// Close writes the close delimiter and returns the whole message.
+func (w *multipartWriter) Close() []byte {
+	w.WriteString("--")
+	w.WriteString(w.boundary)
+	w.WriteString("--\r\n")
+	return w.Bytes()
+}
+
+// base64Part returns a MIME part with the base64 encoded content.
+func base64Part(header string, content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+
+	var b bytes.Buffer
+	b.Grow(len(header) + len(encoded) + len(encoded)/76*2 + 64)
+	b.WriteString(header)
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	return b.Bytes()
+}
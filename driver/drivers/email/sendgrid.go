@@ -0,0 +1,259 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/knadh/smtppool"
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/builder"
+	"github.com/xgfone/go-toolkit/jsonx"
+	"github.com/xgfone/go-toolkit/unsafex"
+)
+
+// DriverTypeSendGrid represents the driver type "sendgrid".
+const DriverTypeSendGrid = "sendgrid"
+
+func init() { builder.NewAndRegister(DriverTypeSendGrid, NewSendGrid) }
+
+// NewSendGrid returns a new driver, which builds the same email as the driver
+// "email" and sends it by the SendGrid v3 mail send api, which is registered
+// as the driver builder with name "sendgrid" and type DriverTypeSendGrid by default.
+//
+// config options:
+//
+//	apikey(string, required): the api key of SendGrid.
+//	from(string, required): the address to send email, such as "username@mail.example.com".
+//	allowfrom([]string|string, optional): the addresses or domains like "@example.com" allowed as the per-message sender, separated by the whitespace for string.
+//	unsubscribeurl(string, optional): the https url template to unsubscribe by one click, such as "https://example.com/unsubscribe?email={receiver}".
+//	unsubscribemailto(string, optional): the address to unsubscribe by email, such as "unsubscribe@example.com".
+//	endpoint(string, optional): the url of the mail send api. default "https://api.sendgrid.com/v3/mail/send".
+//	attachmentdir(string, optional): the directory where the attachment files are located. If empty, disable the attachment file.
+//	maxattachmentsize(int|int64|uint|uint64, optional): the maximum total size in bytes of all the attachments. default 10MB, 0 means no limit.
+//	timeout(int|int64|uint|uint64|string, optional): the timeout to call the api. If integer, stand for second. default 10s.
+//
+// The message content and metadata support the same fields as the driver "email",
+// and so do Message-ID, MessageKey, ThreadKey and List-Unsubscribe, but DKIM, S/MIME
+// and PGP/MIME are not supported. The message id returned by SendGrid
+// is reported by driver.AddMessageID. The headers reserved by SendGrid,
+// such as "X-SG-ID" and "Received", are not allowed in Headers.
+//
+// Notice: The returned driver supports the comma-separated receiver list,
+// which is parsed by RFC 5322, see email.ParseAddressList.
+func NewSendGrid(name string, config map[string]any) (driver.Driver, error) {
+	eb, err := newEmailBuilder(name, config)
+	if err != nil {
+		return nil, err
+	} else if eb.dkim != nil || eb.smime != nil || eb.pgp != nil {
+		return nil, errors.New("dkim, smime and pgp are not supported by sendgrid")
+	}
+
+	apikey, err := getString(config, "apikey")
+	if err != nil {
+		return nil, err
+	} else if apikey == "" {
+		return nil, errors.New("apikey is missing or invalid")
+	}
+
+	client, err := newAPIClient(config, DriverTypeSendGrid, "https://api.sendgrid.com/v3/mail/send")
+	if err != nil {
+		return nil, err
+	}
+
+	return sendgridDriver{name: name, builder: eb, client: client, apikey: apikey}, nil
+}
+
+type sendgridDriver struct {
+	builder emailBuilder
+	client  apiClient
+	apikey  string
+	name    string
+}
+
+func (d sendgridDriver) Stop()        {}
+func (d sendgridDriver) Name() string { return d.name }
+func (d sendgridDriver) Type() string { return DriverTypeSendGrid }
+func (d sendgridDriver) Send(c context.Context, m driver.Message) (err error) {
+	mail, err := d.builder.Build(m)
+	if err != nil {
+		return
+	}
+
+	data, err := newSendGridRequest(mail)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.client.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	_, header, err := d.client.Do(c, req, func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+d.apikey)
+		r.Header.Set("Content-Type", "application/json")
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	driver.AddMessageID(c, header.Get("X-Message-Id"))
+	return
+}
+
+// sendgridReservedHeaders are the headers which SendGrid does not allow
+// to set by the field "headers" of the request.
+var sendgridReservedHeaders = map[string]struct{}{
+	"X-Sg-Id":                   {},
+	"X-Sg-Eid":                  {},
+	"Received":                  {},
+	"Dkim-Signature":            {},
+	"Content-Type":              {},
+	"Content-Transfer-Encoding": {},
+	"To":                        {},
+	"From":                      {},
+	"Subject":                   {},
+	"Reply-To":                  {},
+	"Cc":                        {},
+	"Bcc":                       {},
+}
+
+type sendgridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendgridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+func newSendGridRequest(mail smtppool.Email) ([]byte, error) {
+	type (
+		Personalization struct {
+			To  []sendgridAddress `json:"to"`
+			Cc  []sendgridAddress `json:"cc,omitempty"`
+			Bcc []sendgridAddress `json:"bcc,omitempty"`
+		}
+
+		Content struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		}
+
+		Request struct {
+			Personalizations []Personalization    `json:"personalizations"`
+			From             sendgridAddress      `json:"from"`
+			ReplyToList      []sendgridAddress    `json:"reply_to_list,omitempty"`
+			Subject          string               `json:"subject"`
+			Content          []Content            `json:"content"`
+			Attachments      []sendgridAttachment `json:"attachments,omitempty"`
+			Headers          map[string]string    `json:"headers,omitempty"`
+		}
+	)
+
+	var p Personalization
+	var req Request
+	var err error
+
+	if req.From, err = toSendGridAddress(mail.From); err != nil {
+		return nil, err
+	}
+	if p.To, err = toSendGridAddresses(mail.To); err != nil {
+		return nil, err
+	}
+	if p.Cc, err = toSendGridAddresses(mail.Cc); err != nil {
+		return nil, err
+	}
+	if p.Bcc, err = toSendGridAddresses(mail.Bcc); err != nil {
+		return nil, err
+	}
+	if req.ReplyToList, err = toSendGridAddresses(mail.ReplyTo); err != nil {
+		return nil, err
+	}
+
+	req.Subject = mail.Subject
+	req.Personalizations = []Personalization{p}
+
+	// SendGrid requires that text/plain is before text/html.
+	if len(mail.Text) > 0 {
+		req.Content = append(req.Content, Content{Type: smtppool.ContentTypePlain, Value: unsafex.String(mail.Text)})
+	}
+	if len(mail.HTML) > 0 {
+		req.Content = append(req.Content, Content{Type: smtppool.ContentTypeHTML, Value: unsafex.String(mail.HTML)})
+	}
+
+	if len(mail.Headers) > 0 {
+		req.Headers = make(map[string]string, len(mail.Headers))
+		for key := range mail.Headers {
+			if _, ok := sendgridReservedHeaders[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+				return nil, fmt.Errorf("header '%s' is reserved by sendgrid", key)
+			}
+			req.Headers[key] = mail.Headers.Get(key)
+		}
+	}
+
+	if len(mail.Attachments) > 0 {
+		req.Attachments = make([]sendgridAttachment, len(mail.Attachments))
+		for i, a := range mail.Attachments {
+			disposition, _, _ := mime.ParseMediaType(a.Header.Get(smtppool.HdrContentDisposition))
+			req.Attachments[i] = sendgridAttachment{
+				Content:     base64.StdEncoding.EncodeToString(a.Content),
+				Type:        a.Header.Get(smtppool.HdrContentType),
+				Filename:    a.Filename,
+				Disposition: disposition,
+				ContentID:   strings.Trim(a.Header.Get(smtppool.HdrContentID), "<>"),
+			}
+		}
+	}
+
+	return jsonx.Marshal(req)
+}
+
+func toSendGridAddress(s string) (sendgridAddress, error) {
+	addr, err := netmail.ParseAddress(s)
+	if err != nil {
+		return sendgridAddress{}, fmt.Errorf("invalid address '%s': %w", s, err)
+	}
+	return sendgridAddress{Email: addr.Address, Name: addr.Name}, nil
+}
+
+func toSendGridAddresses(ss []string) (addrs []sendgridAddress, err error) {
+	if len(ss) == 0 {
+		return
+	}
+
+	addrs = make([]sendgridAddress, len(ss))
+	for i, s := range ss {
+		if addrs[i], err = toSendGridAddress(s); err != nil {
+			return
+		}
+	}
+	return
+}
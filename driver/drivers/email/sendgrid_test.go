@@ -0,0 +1,102 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestSendGrid(t *testing.T) {
+	endpoint, req := newAPIServer(t, 202, map[string]string{"X-Message-Id": "sgid"}, "")
+	d, err := NewSendGrid("sendgrid", map[string]any{
+		"apikey":   "key",
+		"from":     "Sender <sender@example.com>",
+		"endpoint": endpoint + "/v3/mail/send",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result driver.Result
+	c := driver.WithResult(context.Background(), &result)
+	msg := Message{
+		Subject:   "subject",
+		Content:   "<p>content</p>",
+		Cc:        []string{"Cc <cc@example.com>"},
+		Headers:   map[string]string{"X-Priority": "1"},
+		ThreadKey: "thread",
+	}
+	if err := d.Send(c, driver.NewMessage("", "", "to@example.com", msg, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "POST" || req.Path != "/v3/mail/send" {
+		t.Errorf("unexpected request %s %s", req.Method, req.Path)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "Bearer key" {
+		t.Errorf("unexpected Authorization '%s'", auth)
+	}
+	if ids := result.MessageIDs(); !reflect.DeepEqual(ids, []string{"sgid"}) {
+		t.Errorf("unexpected message ids %v", ids)
+	}
+
+	var body struct {
+		Personalizations []struct{ To, Cc []sendgridAddress }
+		From             sendgridAddress
+		Subject          string
+		Content          []struct{ Type, Value string }
+		Headers          map[string]string
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case body.From != sendgridAddress{Email: "sender@example.com", Name: "Sender"}:
+		t.Errorf("unexpected from %+v", body.From)
+	case body.Subject != "subject":
+		t.Errorf("unexpected subject '%s'", body.Subject)
+	case len(body.Personalizations) != 1:
+		t.Errorf("expect 1 personalization, but got %d", len(body.Personalizations))
+	case !reflect.DeepEqual(body.Personalizations[0].To, []sendgridAddress{{Email: "to@example.com"}}):
+		t.Errorf("unexpected to %+v", body.Personalizations[0].To)
+	case !reflect.DeepEqual(body.Personalizations[0].Cc, []sendgridAddress{{Email: "cc@example.com", Name: "Cc"}}):
+		t.Errorf("unexpected cc %+v", body.Personalizations[0].Cc)
+	case len(body.Content) != 2 || body.Content[0].Type != "text/plain" || body.Content[1].Type != "text/html":
+		t.Errorf("expect text/plain before text/html, but got %+v", body.Content)
+	case body.Content[1].Value != "<p>content</p>":
+		t.Errorf("unexpected html content '%s'", body.Content[1].Value)
+	case len(body.Headers) != 4 || body.Headers["X-Priority"] != "1" || body.Headers["Message-Id"] == "":
+		t.Errorf("unexpected headers %v", body.Headers)
+	case body.Headers["In-Reply-To"] == "" || body.Headers["In-Reply-To"] != body.Headers["References"]:
+		t.Errorf("unexpected thread headers %v", body.Headers)
+	}
+
+	// The headers reserved by SendGrid are refused.
+	msg.Headers = map[string]string{"X-SG-ID": "id"}
+	if err := d.Send(c, driver.NewMessage("", "", "to@example.com", msg, nil)); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("expect a reserved header error, but got %v", err)
+	}
+
+	testAPIError(t, func(endpoint string) (driver.Driver, error) {
+		return NewSendGrid("sendgrid", map[string]any{"apikey": "key", "from": "sender@example.com", "endpoint": endpoint})
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/knadh/smtppool"
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/builder"
+)
+
+// DriverTypeSendmail represents the driver type "sendmail".
+const DriverTypeSendmail = "sendmail"
+
+// exTempFail is the exit code EX_TEMPFAIL defined in sysexits.h.
+const exTempFail = 75
+
+func init() { builder.NewAndRegister(DriverTypeSendmail, NewSendmail) }
+
+// SendmailError represents the error that the sendmail command exits abnormally.
+type SendmailError struct {
+	Code   int    // The exit code of the command.
+	Output string // The output of the command, which may be empty.
+}
+
+// Error implements the interface error.
+func (e SendmailError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("sendmail exited with code %d", e.Code)
+	}
+	return fmt.Sprintf("sendmail exited with code %d: %s", e.Code, e.Output)
+}
+
+// Temporary reports whether the error is temporary, that's, EX_TEMPFAIL.
+func (e SendmailError) Temporary() bool { return e.Code == exTempFail }
+
+// NewSendmail returns a new driver, which builds the same html email
+// as the driver "email" and pipes it to a sendmail compatible binary,
+// which is registered as the driver builder with name "sendmail"
+// and type DriverTypeSendmail by default.
+//
+// config options:
+//
+//	from(string, required): the address to send email, such as "username@mail.example.com".
+//	allowfrom([]string|string, optional): the addresses or domains like "@example.com" allowed as the per-message sender, separated by the whitespace for string.
+//	unsubscribeurl(string, optional): the https url template to unsubscribe by one click, such as "https://example.com/unsubscribe?email={receiver}".
+//	unsubscribemailto(string, optional): the address to unsubscribe by email, such as "unsubscribe@example.com".
+//	path(string, optional): the path of the sendmail binary, which is looked up when sending. default "/usr/sbin/sendmail".
+//	args([]string|string, optional): the arguments of the sendmail binary. default "-t -i".
+//	attachmentdir(string, optional): the directory where the attachment files are located. If empty, disable the attachment file.
+//	maxattachmentsize(int|int64|uint|uint64, optional): the maximum total size in bytes of all the attachments. default 10MB, 0 means no limit.
+//	dkimdomain(string, optional): the signing domain of DKIM, such as "example.com". If empty, disable DKIM.
+//	dkimselector(string, optional): the selector of DKIM, which is required if dkimdomain is set.
+//	dkimkey(string, optional): the PEM private key of DKIM, RSA or Ed25519, in PKCS#1 or PKCS#8.
+//	dkimkeyfile(string, optional): the file of the PEM private key of DKIM if dkimkey is not set.
+//	dkimheaders([]string|string, optional): the headers to be signed if they exist, which must contain "From".
+//	smimecertfile(string, optional): the file of the PEM S/MIME certificate with the chain to sign the email, which must be used with smimekeyfile.
+//	smimekeyfile(string, optional): the file of the PEM private key of the S/MIME certificate.
+//	pgpkey(string, optional): the armored PGP private key to sign the email.
+//	pgpkeyfile(string, optional): the file of the armored PGP private key if pgpkey is not set.
+//	pgppassphrase(string, optional): the passphrase to decrypt the PGP private key.
+//	pgpencrypt(string, optional): the PGP encryption mode, such as "none", "optional" or "required". default "none".
+//	pgpkeys(map[string]string, optional): the armored PGP public keys of the recipients indexed by the address.
+//	pgplookup(func(context.Context, string) (string, error), optional): the function to look up the armored PGP public key of the recipient address not in pgpkeys, which returns "" if no key.
+//	timeout(int|int64|uint|uint64|string, optional): the timeout to run the binary. If integer, stand for second. default 10s.
+//
+// The message content and metadata support the same fields as the driver "email",
+// and so do Message-ID, MessageKey, ThreadKey, List-Unsubscribe, DKIM, S/MIME and PGP/MIME.
+//
+// Notice: The returned driver supports the comma-separated receiver list,
+// which is parsed by RFC 5322, see email.ParseAddressList.
+func NewSendmail(name string, config map[string]any) (driver.Driver, error) {
+	eb, err := newEmailBuilder(name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := getString(config, "path")
+	if err != nil {
+		return nil, err
+	} else if path == "" {
+		path = "/usr/sbin/sendmail"
+	}
+
+	args, err := getStrings(config, "args", []string{"-t", "-i"})
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := getDuration(config, "timeout", 10*time.Second)
+	if err != nil {
+		return nil, err
+	} else if timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout '%s'", timeout)
+	}
+
+	return sendmailDriver{name: name, builder: eb, path: path, args: args, timeout: timeout}, nil
+}
+
+type sendmailDriver struct {
+	builder emailBuilder
+	name    string
+	path    string
+	args    []string
+	timeout time.Duration
+}
+
+func (d sendmailDriver) Stop()        {}
+func (d sendmailDriver) Name() string { return d.name }
+func (d sendmailDriver) Type() string { return DriverTypeSendmail }
+func (d sendmailDriver) Send(c context.Context, m driver.Message) (err error) {
+	mail, err := d.builder.Build(m)
+	if err != nil {
+		return
+	}
+
+	// smtppool does not write the Bcc header, but sendmail with "-t" reads
+	// the recipients from the headers and removes the Bcc header itself.
+	if len(mail.Bcc) > 0 {
+		bcc := make([]string, len(mail.Bcc))
+		for i, s := range mail.Bcc {
+			addr, err := netmail.ParseAddress(s)
+			if err != nil {
+				return fmt.Errorf("invalid recipient '%s': %w", s, err)
+			}
+			bcc[i] = addr.String()
+		}
+		mail.Headers.Set("Bcc", strings.Join(bcc, ", "))
+	}
+
+	data, err := d.builder.Encode(c, mail)
+	if err != nil {
+		return
+	}
+
+	c, cancel := context.WithTimeout(c, d.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(c, d.path, d.args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = time.Second
+
+	if err = cmd.Run(); err != nil {
+		if cerr := c.Err(); cerr != nil {
+			return fmt.Errorf("fail to run sendmail: %w", cerr)
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return SendmailError{Code: exitErr.ExitCode(), Output: strings.TrimSpace(output.String())}
+		}
+		return fmt.Errorf("fail to run sendmail: %w", err)
+	}
+
+	driver.AddMessageID(c, mail.Headers.Get(smtppool.HdrMessageID))
+	return
+}
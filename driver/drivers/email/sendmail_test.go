@@ -0,0 +1,82 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/tools/email"
+)
+
+func TestSendmail(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sendmail is a shell script")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sendmail")
+	config := map[string]any{"from": "Sender <sender@example.com>", "path": path}
+
+	// The binary may be installed after the driver is built.
+	d, err := NewSendmail("sendmail", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := "#!/bin/sh\necho \"$@\" > " + dir + "/args\ncat > " + dir + "/stdin\n" +
+		"grep -q 'Subject: fail' " + dir + "/stdin && { echo 'try again'; exit 75; }\nexit 0\n"
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := email.Message{Subject: "subject", Content: "<p>content</p>", Bcc: []string{"bcc@example.com"}}
+	if err := d.Send(context.Background(), driver.Message{Receiver: "to@example.com", Content: msg}); err != nil {
+		t.Fatal(err)
+	}
+
+	if args, _ := os.ReadFile(filepath.Join(dir, "args")); string(args) != "-t -i\n" {
+		t.Errorf("unexpected args %q", args)
+	}
+
+	stdin, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	for _, header := range []string{
+		"From: \"Sender\" <sender@example.com>\r\n",
+		"To: <to@example.com>\r\n",
+		"Bcc: <bcc@example.com>\r\n",
+		"Subject: subject\r\n",
+		"Message-Id: <",
+	} {
+		if !strings.Contains(string(stdin), header) {
+			t.Errorf("missing the header %q in %q", header, stdin)
+		}
+	}
+	if !strings.Contains(string(stdin), "<p>content</p>") {
+		t.Errorf("missing the content in %q", stdin)
+	}
+
+	var serr SendmailError
+	msg.Subject = "fail"
+	err = d.Send(context.Background(), driver.Message{Receiver: "to@example.com", Content: msg})
+	if !errors.As(err, &serr) || !serr.Temporary() || serr.Output != "try again" {
+		t.Errorf("expect a temporary sendmail error, but got %v", err)
+	}
+}
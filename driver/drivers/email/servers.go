@@ -0,0 +1,303 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The strategies to select the smtp server.
+const (
+	strategyFailover   = "failover"
+	strategyRoundRobin = "roundrobin"
+	strategyWeighted   = "weighted"
+)
+
+type smtpServer struct {
+	pool   *smtpPool
+	weight int
+	downto atomic.Int64 // The unix nanoseconds until which the server is unhealthy.
+}
+
+func (s *smtpServer) healthy(now int64) bool { return s.downto.Load() <= now }
+
+// serverGroup is a group of the smtp servers, which selects the server
+// to send the email by the strategy, and fails over to the next one
+// if it fails to connect to or authenticate with the server.
+type serverGroup struct {
+	servers  []*smtpServer
+	strategy string
+	cooldown time.Duration
+
+	next atomic.Uint64 // For roundrobin
+
+	lock    sync.Mutex // For weighted
+	current []int
+}
+
+func newServerGroup(config map[string]any) (g *serverGroup, err error) {
+	configs, err := getServerConfigs(config)
+	if err != nil {
+		return
+	}
+
+	g = &serverGroup{servers: make([]*smtpServer, 0, len(configs))}
+	defer func() {
+		if err != nil {
+			g.Close()
+		}
+	}()
+
+	if g.strategy, err = getString(config, "strategy"); err != nil {
+		return
+	}
+	switch g.strategy = strings.ToLower(g.strategy); g.strategy {
+	case "":
+		g.strategy = strategyFailover
+	case strategyFailover, strategyRoundRobin, strategyWeighted:
+	default:
+		return g, fmt.Errorf("unsupported strategy '%s'", g.strategy)
+	}
+
+	if g.cooldown, err = getDuration(config, "cooldown", 30*time.Second); err != nil {
+		return
+	}
+
+	for i, config := range configs {
+		server := new(smtpServer)
+		if server.weight, err = getInt(config, "weight", 1); err != nil {
+			return g, fmt.Errorf("servers[%d]: %w", i, err)
+		} else if server.weight < 1 {
+			return g, fmt.Errorf("servers[%d]: invalid weight %d", i, server.weight)
+		}
+
+		if server.pool, err = newServerPool(config); err != nil {
+			if len(configs) > 1 {
+				err = fmt.Errorf("servers[%d]: %w", i, err)
+			}
+			return
+		}
+
+		g.servers = append(g.servers, server)
+	}
+	g.current = make([]int, len(g.servers))
+
+	return
+}
+
+// getServerConfigs returns the configs of all the servers, each of which
+// inherits the missing options from the top-level config.
+func getServerConfigs(config map[string]any) ([]map[string]any, error) {
+	var servers []map[string]any
+	switch v := config["servers"].(type) {
+	case nil:
+		return []map[string]any{config}, nil
+
+	case []map[string]any:
+		servers = v
+
+	case []any:
+		servers = make([]map[string]any, len(v))
+		for i, server := range v {
+			var ok bool
+			if servers[i], ok = server.(map[string]any); !ok {
+				return nil, fmt.Errorf("servers[%d] expects a map[string]any, but got %T", i, server)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported servers type %T", v)
+	}
+
+	if len(servers) == 0 {
+		return nil, errors.New("servers is empty")
+	}
+
+	configs := make([]map[string]any, len(servers))
+	for i, server := range servers {
+		configs[i] = make(map[string]any, len(config)+len(server))
+		for key, value := range config {
+			if key != "servers" {
+				configs[i][key] = value
+			}
+		}
+		for key, value := range server {
+			configs[i][key] = value
+		}
+	}
+
+	return configs, nil
+}
+
+func newServerPool(config map[string]any) (*smtpPool, error) {
+	addr, _ := config["addr"].(string)
+	if addr == "" {
+		return nil, errors.New("addr is missing or invalid")
+	}
+
+	maxconnnum, err := getInt(config, "maxconnnum", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := getDuration(config, "timeout", 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	idleTimeout, err := getDuration(config, "idletimeout", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsmode, err := getTLSMode(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var port int
+	var host string
+	if _host, _port, err := net.SplitHostPort(addr); err == nil {
+		host = _host
+		v, err := strconv.ParseUint(_port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %w", err)
+		}
+		port = int(v)
+	} else {
+		host = addr
+		port = getDefaultPort(config, tlsmode)
+	}
+
+	tlsconf, err := newTLSConfig(config, host)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := newAuth(config, host, tlsmode)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSMTPPool(poolOption{
+		Host:      host,
+		Port:      port,
+		TLSMode:   tlsmode,
+		TLSConfig: tlsconf,
+		Auth:      auth,
+
+		MaxConns:    maxconnnum,
+		IdleTimeout: idleTimeout,
+		WaitTimeout: timeout,
+	})
+}
+
+// Close closes the pools of all the servers.
+func (g *serverGroup) Close() {
+	for _, server := range g.servers {
+		server.pool.Close()
+	}
+}
+
+// Send sends the raw message by the servers in the order of the strategy.
+//
+// If it fails to connect to or authenticate with a server, the server is
+// marked as unhealthy for the cooldown, and the next server is tried.
+func (g *serverGroup) Send(ctx context.Context, from string, to []string, msg []byte) (err error) {
+	for _, server := range g.order() {
+		if err = server.pool.Send(ctx, from, to, msg); err == nil {
+			return
+		}
+
+		var cerr connError
+		if !errors.As(err, &cerr) || ctx.Err() != nil {
+			return
+		}
+
+		if len(g.servers) > 1 {
+			server.downto.Store(time.Now().Add(g.cooldown).UnixNano())
+		}
+	}
+	return
+}
+
+// order returns the servers in the order to be tried,
+// and the unhealthy ones are moved to the end.
+func (g *serverGroup) order() []*smtpServer {
+	if len(g.servers) == 1 {
+		return g.servers
+	}
+
+	var first int
+	switch g.strategy {
+	case strategyRoundRobin:
+		first = int((g.next.Add(1) - 1) % uint64(len(g.servers)))
+	case strategyWeighted:
+		first = g.selectWeighted()
+	}
+
+	now := time.Now().UnixNano()
+	servers := make([]*smtpServer, 0, len(g.servers))
+	for i := range g.servers {
+		if server := g.servers[(first+i)%len(g.servers)]; server.healthy(now) {
+			servers = append(servers, server)
+		}
+	}
+	for i := range g.servers {
+		if server := g.servers[(first+i)%len(g.servers)]; !server.healthy(now) {
+			servers = append(servers, server)
+		}
+	}
+
+	return servers
+}
+
+// selectWeighted selects the server by the smooth weighted round-robin
+// among the healthy servers, and returns its index.
+func (g *serverGroup) selectWeighted() (index int) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := time.Now().UnixNano()
+
+	var total int
+	index = -1
+	for i, server := range g.servers {
+		if !server.healthy(now) {
+			continue
+		}
+
+		total += server.weight
+		g.current[i] += server.weight
+		if index < 0 || g.current[i] > g.current[index] {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		return 0
+	}
+
+	g.current[index] -= total
+	return
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServerGroup returns a server group of the fake smtp servers,
+// which are configured by the server configs in order.
+func newTestServerGroup(t *testing.T, config map[string]any, servers []*fakeSMTPServer, configs []map[string]any) *serverGroup {
+	list := make([]any, len(servers))
+	for i, s := range servers {
+		host, port := newFakeSMTPServer(t, s)
+		server := map[string]any{"addr": net.JoinHostPort(host, strconv.Itoa(port))}
+		if i < len(configs) {
+			for key, value := range configs[i] {
+				server[key] = value
+			}
+		}
+		list[i] = server
+	}
+
+	config["servers"] = list
+	config["tls"] = tlsNone
+	g, err := newServerGroup(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(g.Close)
+	return g
+}
+
+// receivers returns the indexes of the servers receiving the mails
+// to "toN@example.com" in the order of N.
+func receivers(servers []*fakeSMTPServer, n int) []int {
+	indexes := make([]int, n)
+	for i, s := range servers {
+		_, mails, _ := s.stats()
+		for _, mail := range mails {
+			var to int
+			if _, err := fmt.Sscanf(mail, "To: to%d@", &to); err == nil && to < n {
+				indexes[to] = i
+			}
+		}
+	}
+	return indexes
+}
+
+func sendTo(g *serverGroup, i int) error {
+	to := fmt.Sprintf("to%d@example.com", i)
+	return g.Send(context.Background(), "from@example.com", []string{to}, []byte("To: "+to+"\r\n\r\nbody\r\n"))
+}
+
+func TestServerGroupFailover(t *testing.T) {
+	servers := []*fakeSMTPServer{
+		{username: "user", password: "other"}, // Fail to authenticate.
+		{username: "user", password: "pass"},
+	}
+	config := map[string]any{"strategy": "failover", "cooldown": "100ms", "username": "user", "password": "pass"}
+	g := newTestServerGroup(t, config, servers, nil)
+
+	// The first server fails and is skipped until the cooldown expires.
+	for i := range 3 {
+		if err := sendTo(g, i); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	if dials, _, _ := servers[0].stats(); dials != 1 {
+		t.Errorf("expect the failed server is dialed once, but got %d", dials)
+	}
+	if expect, indexes := []int{1, 1, 1}, receivers(servers, 3); !reflect.DeepEqual(indexes, expect) {
+		t.Errorf("expect receivers %v, but got %v", expect, indexes)
+	}
+
+	// The cooldown expires, and the first server is tried again.
+	time.Sleep(150 * time.Millisecond)
+	if err := sendTo(g, 3); err != nil {
+		t.Fatal(err)
+	}
+	if dials, _, _ := servers[0].stats(); dials != 2 {
+		t.Errorf("expect the failed server is dialed twice, but got %d", dials)
+	}
+
+	// All the servers fail.
+	servers[1].password = "other"
+	servers[1].drop()
+	if err := sendTo(g, 4); err == nil || !strings.Contains(err.Error(), "535") {
+		t.Errorf("expect an authentication error, but got %v", err)
+	}
+}
+
+func TestServerGroupRoundRobin(t *testing.T) {
+	servers := []*fakeSMTPServer{{}, {}, {}}
+	g := newTestServerGroup(t, map[string]any{"strategy": "roundrobin"}, servers, nil)
+
+	for i := range 6 {
+		if err := sendTo(g, i); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+
+	if expect, indexes := []int{0, 1, 2, 0, 1, 2}, receivers(servers, 6); !reflect.DeepEqual(indexes, expect) {
+		t.Errorf("expect receivers %v, but got %v", expect, indexes)
+	}
+}
+
+func TestServerGroupWeighted(t *testing.T) {
+	servers := []*fakeSMTPServer{{}, {}, {}}
+	configs := []map[string]any{{"weight": 5}, {"weight": 1}, {"weight": 1}}
+	g := newTestServerGroup(t, map[string]any{"strategy": "weighted"}, servers, configs)
+
+	for i := range 14 {
+		if err := sendTo(g, i); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+
+	// The smooth weighted round-robin spreads the heavy server.
+	expect := []int{0, 0, 1, 0, 2, 0, 0, 0, 0, 1, 0, 2, 0, 0}
+	if indexes := receivers(servers, 14); !reflect.DeepEqual(indexes, expect) {
+		t.Errorf("expect receivers %v, but got %v", expect, indexes)
+	}
+
+	// The unhealthy server is excluded from the selection.
+	g.servers[0].downto.Store(time.Now().Add(time.Hour).UnixNano())
+	for range 4 {
+		if first := g.order()[0]; first == g.servers[0] {
+			t.Errorf("unexpect the unhealthy server is selected first")
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/builder"
+	"github.com/xgfone/go-toolkit/jsonx"
+)
+
+// DriverTypeSES represents the driver type "ses".
+const DriverTypeSES = "ses"
+
+func init() { builder.NewAndRegister(DriverTypeSES, NewSES) }
+
+// NewSES returns a new driver, which builds the same email as the driver
+// "email" and sends it as the raw email by the Amazon SES v2 SendEmail api,
+// which is registered as the driver builder with name "ses"
+// and type DriverTypeSES by default.
+//
+// config options:
+//
+//	region(string, required): the aws region, such as "us-east-1". default the env AWS_REGION.
+//	accesskeyid(string, required): the aws access key id. default the env AWS_ACCESS_KEY_ID.
+//	secretaccesskey(string, required): the aws secret access key. default the env AWS_SECRET_ACCESS_KEY.
+//	sessiontoken(string, optional): the aws session token of the temporary credential. default the env AWS_SESSION_TOKEN.
+//	configurationset(string, optional): the name of the SES configuration set.
+//	from(string, required): the address to send email, such as "username@mail.example.com".
+//	allowfrom([]string|string, optional): the addresses or domains like "@example.com" allowed as the per-message sender, separated by the whitespace for string.
+//	unsubscribeurl(string, optional): the https url template to unsubscribe by one click, such as "https://example.com/unsubscribe?email={receiver}".
+//	unsubscribemailto(string, optional): the address to unsubscribe by email, such as "unsubscribe@example.com".
+//	endpoint(string, optional): the base url of the api. default "https://email.{region}.amazonaws.com".
+//	attachmentdir(string, optional): the directory where the attachment files are located. If empty, disable the attachment file.
+//	maxattachmentsize(int|int64|uint|uint64, optional): the maximum total size in bytes of all the attachments. default 10MB, 0 means no limit.
+//	timeout(int|int64|uint|uint64|string, optional): the timeout to call the api. If integer, stand for second. default 10s.
+//
+// The message content and metadata support the same fields as the driver "email",
+// and so do Message-ID, MessageKey, ThreadKey, List-Unsubscribe, DKIM, S/MIME and PGP/MIME.
+// The request is signed by AWS Signature Version 4, and the message id
+// returned by SES is reported by driver.AddMessageID.
+//
+// Notice: The returned driver supports the comma-separated receiver list,
+// which is parsed by RFC 5322, see email.ParseAddressList.
+func NewSES(name string, config map[string]any) (driver.Driver, error) {
+	eb, err := newEmailBuilder(name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	getenv := func(key, env string) (value string, err error) {
+		if value, err = getString(config, key); err == nil && value == "" {
+			value = os.Getenv(env)
+		}
+		return
+	}
+
+	var d sesDriver
+	if d.region, err = getenv("region", "AWS_REGION"); err != nil {
+		return nil, err
+	} else if d.region == "" {
+		return nil, errors.New("region is missing or invalid")
+	}
+
+	if d.keyid, err = getenv("accesskeyid", "AWS_ACCESS_KEY_ID"); err != nil {
+		return nil, err
+	} else if d.keyid == "" {
+		return nil, errors.New("accesskeyid is missing or invalid")
+	}
+
+	if d.secret, err = getenv("secretaccesskey", "AWS_SECRET_ACCESS_KEY"); err != nil {
+		return nil, err
+	} else if d.secret == "" {
+		return nil, errors.New("secretaccesskey is missing or invalid")
+	}
+
+	if d.token, err = getenv("sessiontoken", "AWS_SESSION_TOKEN"); err != nil {
+		return nil, err
+	}
+
+	if d.confset, err = getString(config, "configurationset"); err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("https://email.%s.amazonaws.com", d.region)
+	if d.client, err = newAPIClient(config, DriverTypeSES, endpoint); err != nil {
+		return nil, err
+	}
+
+	d.name = name
+	d.builder = eb
+	d.url = d.client.endpoint + "/v2/email/outbound-emails"
+	return d, nil
+}
+
+type sesDriver struct {
+	builder emailBuilder
+	client  apiClient
+	name    string
+	url     string
+
+	region  string
+	keyid   string
+	secret  string
+	token   string
+	confset string
+}
+
+func (d sesDriver) Stop()        {}
+func (d sesDriver) Name() string { return d.name }
+func (d sesDriver) Type() string { return DriverTypeSES }
+func (d sesDriver) Send(c context.Context, m driver.Message) (err error) {
+	mail, err := d.builder.Build(m)
+	if err != nil {
+		return
+	}
+
+	from, to, err := getEnvelope(mail)
+	if err != nil {
+		return
+	}
+
+	data, err := d.builder.Encode(c, mail)
+	if err != nil {
+		return
+	}
+
+	// For the raw email, Destination is the envelope recipients,
+	// and the headers To and Cc are carried by the raw content.
+	type Request struct {
+		FromEmailAddress     string
+		Destination          struct{ ToAddresses []string }
+		Content              struct{ Raw struct{ Data []byte } }
+		ConfigurationSetName string `json:",omitempty"`
+	}
+
+	var r Request
+	r.FromEmailAddress = from
+	r.Content.Raw.Data = data // Encoded by base64 in JSON
+	r.ConfigurationSetName = d.confset
+	r.Destination.ToAddresses = to
+
+	body, err := jsonx.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	resp, _, err := d.client.Do(c, req, func(r *http.Request) error {
+		r.Header.Set("Content-Type", "application/json")
+		if d.token != "" {
+			r.Header.Set("X-Amz-Security-Token", d.token)
+		}
+		signSigV4(r, body, d.keyid, d.secret, d.region, "ses", time.Now())
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var result struct{ MessageId string }
+	if err = jsonx.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("ses: invalid response '%s': %w", resp, err)
+	}
+
+	driver.AddMessageID(c, result.MessageId)
+	return
+}
+
+// signSigV4 signs the request by AWS Signature Version 4,
+// which only supports the request without the query.
+func signSigV4(r *http.Request, body []byte, keyid, secret, region, service string, now time.Time) {
+	now = now.UTC()
+	amzdate := now.Format("20060102T150405Z")
+	date := amzdate[:8]
+
+	payloadhash := sha256.Sum256(body)
+	r.Header.Set("X-Amz-Date", amzdate)
+
+	headers := map[string]string{"host": r.URL.Host}
+	for key, values := range r.Header {
+		headers[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(r.Method)
+	canonical.WriteString("\n")
+	canonical.WriteString(r.URL.EscapedPath())
+	canonical.WriteString("\n")
+	canonical.WriteString(r.URL.RawQuery)
+	canonical.WriteString("\n")
+	for _, name := range names {
+		canonical.WriteString(name)
+		canonical.WriteString(":")
+		canonical.WriteString(strings.TrimSpace(headers[name]))
+		canonical.WriteString("\n")
+	}
+	canonical.WriteString("\n")
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString(signedHeaders)
+	canonical.WriteString("\n")
+	canonical.WriteString(hex.EncodeToString(payloadhash[:]))
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalhash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzdate + "\n" + scope + "\n" + hex.EncodeToString(canonicalhash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		keyid, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestSigV4(t *testing.T) {
+	// The get-vanilla and post-vanilla examples of the AWS Signature Version 4 test suite.
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for method, signature := range map[string]string{
+		http.MethodGet:  "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		http.MethodPost: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+	} {
+		r, _ := http.NewRequest(method, "https://example.amazonaws.com/", nil)
+		signSigV4(r, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+
+		expect := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=" + signature
+		if auth := r.Header.Get("Authorization"); auth != expect {
+			t.Errorf("%s: expect '%s', but got '%s'", method, expect, auth)
+		}
+		if date := r.Header.Get("X-Amz-Date"); date != "20150830T123600Z" {
+			t.Errorf("%s: unexpected X-Amz-Date '%s'", method, date)
+		}
+	}
+}
+
+func TestSES(t *testing.T) {
+	endpoint, req := newAPIServer(t, 200, nil, `{"MessageId":"sesid"}`)
+	d, err := NewSES("ses", map[string]any{
+		"region":           "us-west-2",
+		"accesskeyid":      "keyid",
+		"secretaccesskey":  "secret",
+		"sessiontoken":     "token",
+		"configurationset": "confset",
+		"from":             "sender@example.com",
+		"endpoint":         endpoint,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result driver.Result
+	c := driver.WithResult(context.Background(), &result)
+	msg := Message{Subject: "subject", Content: "content", Cc: []string{"cc@example.com"}}
+	if err := d.Send(c, driver.NewMessage("", "", "to@example.com", msg, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "POST" || req.Path != "/v2/email/outbound-emails" {
+		t.Errorf("unexpected request %s %s", req.Method, req.Path)
+	}
+	if token := req.Header.Get("X-Amz-Security-Token"); token != "token" {
+		t.Errorf("unexpected X-Amz-Security-Token '%s'", token)
+	}
+	if ids := result.MessageIDs(); !reflect.DeepEqual(ids, []string{"sesid"}) {
+		t.Errorf("unexpected message ids %v", ids)
+	}
+
+	date := req.Header.Get("X-Amz-Date")
+	auth := req.Header.Get("Authorization")
+	prefix := "AWS4-HMAC-SHA256 Credential=keyid/" + date[:min(8, len(date))] + "/us-west-2/ses/aws4_request, " +
+		"SignedHeaders=content-type;host;"
+	if !strings.HasPrefix(auth, prefix) || !strings.Contains(auth, ";x-amz-date;x-amz-security-token, Signature=") {
+		t.Errorf("unexpected Authorization '%s'", auth)
+	}
+
+	var body struct {
+		FromEmailAddress     string
+		Destination          struct{ ToAddresses []string }
+		Content              struct{ Raw struct{ Data []byte } }
+		ConfigurationSetName string
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case body.FromEmailAddress != "sender@example.com":
+		t.Errorf("unexpected from '%s'", body.FromEmailAddress)
+	case !reflect.DeepEqual(body.Destination.ToAddresses, []string{"to@example.com", "cc@example.com"}):
+		t.Errorf("unexpected destination %v", body.Destination.ToAddresses)
+	case body.ConfigurationSetName != "confset":
+		t.Errorf("unexpected configuration set '%s'", body.ConfigurationSetName)
+	case !strings.Contains(string(body.Content.Raw.Data), "Subject: subject\r\n"):
+		t.Errorf("unexpected raw data %q", body.Content.Raw.Data)
+	}
+
+	testAPIError(t, func(endpoint string) (driver.Driver, error) {
+		return NewSES("ses", map[string]any{"region": "us-east-1", "accesskeyid": "keyid",
+			"secretaccesskey": "secret", "from": "sender@example.com", "endpoint": endpoint})
+	})
+}
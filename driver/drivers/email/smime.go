@@ -0,0 +1,99 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/smallstep/pkcs7"
+)
+
+// smimeSigner signs the email by S/MIME with the detached signature,
+// see RFC 8551.
+type smimeSigner struct {
+	cert    *x509.Certificate
+	parents []*x509.Certificate
+	key     crypto.PrivateKey
+}
+
+// newSMIMESigner builds the S/MIME signer from the config.
+//
+// Return nil if smimecertfile is not set.
+func newSMIMESigner(config map[string]any) (s *smimeSigner, err error) {
+	certfile, err := getString(config, "smimecertfile")
+	if err != nil {
+		return
+	}
+
+	keyfile, err := getString(config, "smimekeyfile")
+	if err != nil {
+		return
+	}
+
+	switch {
+	case certfile == "" && keyfile == "":
+		return nil, nil
+	case certfile == "" || keyfile == "":
+		return nil, errors.New("smimecertfile and smimekeyfile must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certfile, keyfile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smime certificate: %w", err)
+	}
+
+	s = &smimeSigner{key: cert.PrivateKey, parents: make([]*x509.Certificate, len(cert.Certificate)-1)}
+	if s.cert, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, fmt.Errorf("invalid smime certificate: %w", err)
+	}
+	for i, der := range cert.Certificate[1:] {
+		if s.parents[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, fmt.Errorf("invalid smime certificate chain: %w", err)
+		}
+	}
+
+	return
+}
+
+// Sign signs the email message and returns the new one as multipart/signed.
+func (s *smimeSigner) Sign(msg []byte) ([]byte, error) {
+	headers, entity := splitEntity(msg)
+
+	sd, err := pkcs7.NewSignedData(entity)
+	if err != nil {
+		return nil, fmt.Errorf("smime: %w", err)
+	}
+
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err = sd.AddSignerChain(s.cert, s.key, s.parents, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("smime: fail to sign: %w", err)
+	}
+
+	sd.Detach()
+	signature, err := sd.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("smime: fail to sign: %w", err)
+	}
+
+	w := newMultipartWriter(headers, `multipart/signed; protocol="application/pkcs7-signature"; micalg=sha-256`)
+	w.WritePart(entity)
+	w.WritePart(base64Part("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n"+
+		"Content-Disposition: attachment; filename=\"smime.p7s\"\r\n", signature))
+	return w.Close(), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+)
+
+func TestSMIMESigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "from@example.com"},
+		EmailAddresses: []string{"from@example.com"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyder, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certfile, keyfile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	_ = os.WriteFile(certfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	_ = os.WriteFile(keyfile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyder}), 0o600)
+
+	s, err := newSMIMESigner(map[string]any{"smimecertfile": certfile, "smimekeyfile": keyfile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := s.Sign([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, entity := splitEntity([]byte(testMessage))
+	mediatype, parts := splitMultipart(t, msg)
+	if mediatype != "multipart/signed" || len(parts) != 2 {
+		t.Fatalf("expect multipart/signed with 2 parts, but got %s with %d parts", mediatype, len(parts))
+	} else if !bytes.Equal(parts[0], entity) {
+		t.Errorf("expect the signed entity %q, but got %q", entity, parts[0])
+	}
+
+	_, encoded, _ := bytes.Cut(parts[1], []byte("\r\n\r\n"))
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.ReplaceAll(encoded, []byte("\r\n"), nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p7, err := pkcs7.Parse(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	p7.Content = parts[0]
+	if err = p7.VerifyWithChain(roots); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+
+	p7.Content = bytes.Replace(parts[0], []byte("hello"), []byte("world"), 1)
+	if err = p7.VerifyWithChain(roots); err == nil {
+		t.Errorf("expect an error for the modified content, but got nil")
+	}
+}
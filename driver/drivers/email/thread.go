@@ -0,0 +1,131 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/smtppool"
+)
+
+const (
+	hdrInReplyTo           = "In-Reply-To"
+	hdrReferences          = "References"
+	hdrListUnsubscribe     = "List-Unsubscribe"
+	hdrListUnsubscribePost = "List-Unsubscribe-Post"
+)
+
+// newMessageID returns a new unique Message-ID in the domain.
+//
+// If key is not empty, the Message-ID is derived from it instead,
+// which is stable across the sends, such as the retries.
+func newMessageID(domain, key string) string {
+	if key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return fmt.Sprintf("<msg.%s@%s>", hex.EncodeToString(sum[:16]), domain)
+	}
+
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	now := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return fmt.Sprintf("<%s.%s@%s>", now, hex.EncodeToString(b[:]), domain)
+}
+
+// threadID returns the Message-ID of the virtual root email of the thread,
+// which is derived from the thread key and is stable across the sends.
+func threadID(domain, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("<thread.%s@%s>", hex.EncodeToString(sum[:16]), domain)
+}
+
+// unsubscriber is used to build the List-Unsubscribe headers by RFC 2369 and RFC 8058.
+type unsubscriber struct {
+	url    string // The https url template with the placeholders.
+	mailto string
+}
+
+func newUnsubscriber(config map[string]any) (u *unsubscriber, err error) {
+	rawurl, err := getString(config, "unsubscribeurl")
+	if err != nil {
+		return
+	}
+
+	mailto, err := getString(config, "unsubscribemailto")
+	if err != nil {
+		return
+	}
+
+	if rawurl == "" && mailto == "" {
+		return nil, nil
+	}
+
+	if rawurl != "" {
+		uri, err := url.Parse(strings.NewReplacer("{receiver}", "x", "{messageid}", "x").Replace(rawurl))
+		if err != nil || uri.Scheme != "https" || uri.Host == "" {
+			return nil, fmt.Errorf("invalid unsubscribeurl '%s': must be an https url", rawurl)
+		}
+	}
+
+	if mailto != "" {
+		if addr, err := netmail.ParseAddress(mailto); err != nil || addr.Address != mailto {
+			return nil, fmt.Errorf("invalid unsubscribemailto '%s'", mailto)
+		}
+	}
+
+	return &unsubscriber{url: rawurl, mailto: mailto}, nil
+}
+
+// Set sets the List-Unsubscribe headers of the email, and the url template
+// is rendered with the recipients in To and the Message-ID of the email.
+func (u *unsubscriber) Set(mail smtppool.Email) error {
+	var uris []string
+	if u.url != "" {
+		receivers := make([]string, len(mail.To))
+		for i, s := range mail.To {
+			addr, err := netmail.ParseAddress(s)
+			if err != nil {
+				return fmt.Errorf("invalid recipient '%s': %w", s, err)
+			}
+			receivers[i] = addr.Address
+		}
+
+		msgid := strings.Trim(mail.Headers.Get(smtppool.HdrMessageID), "<>")
+		uri := strings.NewReplacer(
+			"{receiver}", url.QueryEscape(strings.Join(receivers, ",")),
+			"{messageid}", url.QueryEscape(msgid),
+		).Replace(u.url)
+
+		uris = append(uris, "<"+uri+">")
+		mail.Headers.Set(hdrListUnsubscribePost, "List-Unsubscribe=One-Click")
+	} else {
+		// The one-click unsubscription requires the https url.
+		mail.Headers.Del(hdrListUnsubscribePost)
+	}
+
+	if u.mailto != "" {
+		uris = append(uris, "<mailto:"+u.mailto+"?subject=unsubscribe>")
+	}
+
+	mail.Headers.Set(hdrListUnsubscribe, strings.Join(uris, ", "))
+	return nil
+}
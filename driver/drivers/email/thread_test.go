@@ -0,0 +1,70 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/knadh/smtppool"
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestMessageID(t *testing.T) {
+	b, err := newEmailBuilder("email", map[string]any{"from": "sender@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(metadata map[string]any) (batch, individual string) {
+		msg := Message{Subject: "subject", Content: "content"}
+		mail, err := b.Build(driver.NewMessage("", "", "a@example.com, b@example.com", msg, metadata))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		one, err := b.WithTo(mail, mail.To[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		batch = mail.Headers.Get(smtppool.HdrMessageID)
+		individual = one.Headers.Get(smtppool.HdrMessageID)
+		if !strings.HasSuffix(batch, "@example.com>") || !strings.HasSuffix(individual, "@example.com>") {
+			t.Errorf("unexpected Message-ID '%s' and '%s'", batch, individual)
+		} else if batch == individual {
+			t.Errorf("expect the individual email has its own Message-ID, but got '%s'", individual)
+		}
+		return
+	}
+
+	batch1, individual1 := build(map[string]any{"MessageKey": "notice-1"})
+	batch2, individual2 := build(map[string]any{"MessageKey": "notice-1"})
+	if batch1 != batch2 || individual1 != individual2 {
+		t.Errorf("expect the stable Message-ID, but got '%s' and '%s', '%s' and '%s'",
+			batch1, batch2, individual1, individual2)
+	}
+
+	batch3, individual3 := build(map[string]any{"MessageKey": "notice-2"})
+	if batch3 == batch1 || individual3 == individual1 {
+		t.Errorf("expect a different Message-ID for another key")
+	}
+
+	batch4, _ := build(nil)
+	batch5, _ := build(nil)
+	if batch4 == batch5 {
+		t.Errorf("expect the random Message-ID without key, but got '%s' twice", batch4)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The modes of TLS to connect to the mail server.
+const (
+	tlsNone     = "none"
+	tlsStartTLS = "starttls"
+	tlsImplicit = "implicit"
+)
+
+func getTLSMode(config map[string]any) (mode string, err error) {
+	if mode, err = getString(config, "tls"); err != nil {
+		return
+	}
+
+	switch mode {
+	case "":
+		// For compatibility
+		var forceTLS bool
+		if forceTLS, err = getBool(config, "forcetls", false); err == nil {
+			if forceTLS {
+				mode = tlsImplicit
+			} else {
+				mode = tlsStartTLS
+			}
+		}
+
+	case tlsNone, tlsStartTLS, tlsImplicit:
+	default:
+		err = fmt.Errorf("unsupported tls mode '%s'", mode)
+	}
+
+	return
+}
+
+func getDefaultPort(config map[string]any, mode string) int {
+	switch mode {
+	case tlsImplicit:
+		return 465
+
+	case tlsStartTLS:
+		// For compatibility, use 25 if the tls mode is not set explicitly.
+		if _, ok := config["tls"]; ok {
+			return 587
+		}
+		return 25
+
+	default:
+		return 25
+	}
+}
+
+func newTLSConfig(config map[string]any, host string) (tlsconf *tls.Config, err error) {
+	servername, err := getString(config, "tlsservername")
+	if err != nil {
+		return
+	} else if servername == "" {
+		servername = host
+	}
+
+	tlsconf = &tls.Config{ServerName: servername}
+
+	minversion, err := getString(config, "tlsminversion")
+	if err != nil {
+		return
+	}
+	switch minversion {
+	case "":
+	case "1.0":
+		tlsconf.MinVersion = tls.VersionTLS10
+	case "1.1":
+		tlsconf.MinVersion = tls.VersionTLS11
+	case "1.2":
+		tlsconf.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsconf.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tlsminversion '%s'", minversion)
+	}
+
+	cafile, err := getString(config, "tlscafile")
+	if err != nil {
+		return
+	} else if cafile != "" {
+		pem, err := os.ReadFile(cafile)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read tlscafile: %w", err)
+		}
+
+		tlsconf.RootCAs = x509.NewCertPool()
+		if !tlsconf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate in tlscafile '%s'", cafile)
+		}
+	}
+
+	certfile, err := getString(config, "tlscertfile")
+	if err != nil {
+		return
+	}
+
+	keyfile, err := getString(config, "tlskeyfile")
+	if err != nil {
+		return
+	}
+
+	switch {
+	case certfile == "" && keyfile == "":
+	case certfile == "" || keyfile == "":
+		return nil, errors.New("tlscertfile and tlskeyfile must be given together")
+	default:
+		cert, err := tls.LoadX509KeyPair(certfile, keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("fail to load the client certificate: %w", err)
+		}
+		tlsconf.Certificates = []tls.Certificate{cert}
+	}
+
+	return
+}
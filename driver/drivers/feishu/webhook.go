@@ -21,7 +21,6 @@ import (
 	"github.com/xgfone/go-msgnotice/driver"
 	"github.com/xgfone/go-msgnotice/driver/builder"
 	"github.com/xgfone/go-msgnotice/tools/feishu"
-	"github.com/xgfone/go-toolkit/mapx"
 )
 
 // DriverTypeWebhook represents the driver type "feishu.webhook".
@@ -33,8 +32,18 @@ func buildWebhook(name string, config map[string]any) (driver.Driver, error) {
 	var secrets map[string]string
 	switch _secrets := config["Secrets"].(type) {
 	case nil:
+	case map[string]string:
+		secrets = _secrets
+
 	case map[string]any:
-		secrets = mapx.Convert(_secrets, func(k string, v any) (string, string) { return k, v.(string) })
+		secrets = make(map[string]string, len(_secrets))
+		for k, v := range _secrets {
+			secret, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expect Secrets['%s'] is a string, but got %T", k, v)
+			}
+			secrets[k] = secret
+		}
 
 	default:
 		return nil, fmt.Errorf("expect Secrets is a map[string]any, but got %T", _secrets)
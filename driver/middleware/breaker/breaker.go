@@ -0,0 +1,347 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package breaker provides a driver middleware of the circuit breaker,
+// which fails fast when the driver keeps failing.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+	"github.com/xgfone/go-msgnotice/driver/middleware/retry"
+)
+
+// State is the state of the circuit breaker.
+type State int
+
+// The states of the circuit breaker.
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Error is returned when the circuit breaker is open,
+// or the probes in the half-open state are exhausted.
+type Error struct {
+	Driver string // The name of the driver.
+	Key    string
+	State  State
+	Until  time.Time // The time when the open state ends.
+}
+
+// Error implements the interface error.
+func (e Error) Error() string {
+	return fmt.Sprintf("breaker: the circuit of the driver '%s' is %s", e.Driver, e.State)
+}
+
+// Temporary returns true, because the circuit becomes half-open
+// after the open state ends, so the message may be sent again then.
+func (e Error) Temporary() bool { return true }
+
+// RetryAfter returns the duration until the open state ends.
+func (e Error) RetryAfter() time.Duration {
+	if e.Until.IsZero() {
+		return 0
+	}
+	return max(0, time.Until(e.Until))
+}
+
+// Config is used to configure the circuit breaker middleware.
+type Config struct {
+	// Key returns the key of the message, and the messages with the same key
+	// share a circuit breaker in the same driver, such as the receiver.
+	//
+	// Default: nil, that's, one circuit breaker per driver.
+	Key func(driver.Message) string
+
+	// ConsecutiveFailures is the number of the consecutive failures
+	// to open the circuit. 0 means to disable it.
+	//
+	// Default: 5
+	ConsecutiveFailures int
+
+	// FailureRatio is the ratio of the failures in Window to open the circuit,
+	// which only takes effect when there are at least MinRequests requests.
+	// 0 means to disable it.
+	FailureRatio float64
+
+	// Default: 20
+	MinRequests int
+
+	// Window is the interval to clear the counts in the closed state.
+	//
+	// Default: 1m
+	Window time.Duration
+
+	// OpenTimeout is the duration of the open state, after which
+	// the state becomes half-open to allow the probe requests.
+	//
+	// Default: 30s
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is the maximum number of the concurrent probe requests
+	// in the half-open state, all of which must succeed to close the circuit.
+	//
+	// Default: 1
+	HalfOpenProbes int
+
+	// IdleTimeout is the duration after which the idle closed breaker
+	// of a key is evicted.
+	//
+	// Default: 10m
+	IdleTimeout time.Duration
+
+	// IsFailure reports whether the error is a failure of the driver.
+	//
+	// Default: the error is transient by retry.IsTransient,
+	// or the context deadline is exceeded.
+	IsFailure func(error) bool
+
+	// OnStateChange is called when the state of a circuit breaker changes,
+	// which may be used to alert.
+	OnStateChange func(d driver.Driver, key string, from, to State)
+}
+
+func isFailure(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || retry.IsTransient(err)
+}
+
+// New returns a new circuit breaker middleware.
+//
+// When the circuit is closed, the messages are sent normally. If the number
+// of the consecutive failures reaches ConsecutiveFailures, or the failure
+// ratio in Window reaches FailureRatio, the circuit is opened, and all the
+// messages fail fast with Error for OpenTimeout. Then the circuit becomes
+// half-open, which allows at most HalfOpenProbes probe requests: if all
+// of them succeed, the circuit is closed; or, it is opened again.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.ConsecutiveFailures < 0 {
+		panic("breaker: ConsecutiveFailures must not be negative")
+	} else if config.ConsecutiveFailures == 0 && config.FailureRatio == 0 {
+		config.ConsecutiveFailures = 5
+	}
+	if config.FailureRatio < 0 || config.FailureRatio > 1 {
+		panic("breaker: FailureRatio must be in [0, 1]")
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 20
+	}
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 10 * time.Minute
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = isFailure
+	}
+
+	return middleware.NewWithMatch("breaker", priority, matcher, func(d driver.Driver) driver.Driver {
+		g := &group{config: &config, breakers: make(map[string]*breaker, 4)}
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
+			var key string
+			if config.Key != nil {
+				key = config.Key(m)
+			}
+
+			gen, err := g.before(d, key)
+			if err != nil {
+				return
+			}
+
+			// Record the panic as a failure and re-panic it, or the probe
+			// in half-open would be never released.
+			panicked := true
+			defer func() {
+				g.after(d, key, gen, panicked || (err != nil && config.IsFailure(err)))
+			}()
+
+			err = d.Send(c, m)
+			panicked = false
+			return
+		})
+	})
+}
+
+type breaker struct {
+	state  State
+	gen    uint64    // Increase when the state changes or the window is cleared.
+	expiry time.Time // The end of the window in closed, or the open state.
+	used   time.Time
+
+	requests    int
+	failures    int
+	consecutive int
+	probes      int // The number of the probe requests in flight.
+	successes   int // The number of the successful probe requests.
+}
+
+type group struct {
+	config *Config
+
+	lock     sync.Mutex
+	swept    time.Time
+	breakers map[string]*breaker
+}
+
+type change struct {
+	from, to State
+}
+
+func (g *group) notify(d driver.Driver, key string, changes []change) {
+	if g.config.OnStateChange != nil {
+		for _, c := range changes {
+			g.config.OnStateChange(d, key, c.from, c.to)
+		}
+	}
+}
+
+func (g *group) before(d driver.Driver, key string) (gen uint64, err error) {
+	var changes []change
+	defer func() { g.notify(d, key, changes) }()
+
+	now := time.Now()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.sweep(now)
+	b := g.breakers[key]
+	if b == nil {
+		b = &breaker{expiry: now.Add(g.config.Window)}
+		g.breakers[key] = b
+	}
+	b.used = now
+
+	switch b.state {
+	case StateClosed:
+		if !now.Before(b.expiry) {
+			b.gen++
+			b.requests, b.failures, b.consecutive = 0, 0, 0
+			b.expiry = now.Add(g.config.Window)
+		}
+
+	case StateOpen:
+		if now.Before(b.expiry) {
+			return 0, Error{Driver: d.Name(), Key: key, State: StateOpen, Until: b.expiry}
+		}
+		changes = append(changes, g.setState(b, StateHalfOpen, now))
+		fallthrough
+
+	case StateHalfOpen:
+		if b.probes >= g.config.HalfOpenProbes {
+			return 0, Error{Driver: d.Name(), Key: key, State: StateHalfOpen}
+		}
+		b.probes++
+	}
+
+	return b.gen, nil
+}
+
+func (g *group) after(d driver.Driver, key string, gen uint64, failed bool) {
+	var changes []change
+	defer func() { g.notify(d, key, changes) }()
+
+	now := time.Now()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	b := g.breakers[key]
+	if b == nil || b.gen != gen {
+		return // The result is from the previous state or window.
+	}
+
+	switch b.state {
+	case StateClosed:
+		b.requests++
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+
+		b.failures++
+		b.consecutive++
+		if (g.config.ConsecutiveFailures > 0 && b.consecutive >= g.config.ConsecutiveFailures) ||
+			(g.config.FailureRatio > 0 && b.requests >= g.config.MinRequests &&
+				float64(b.failures) >= g.config.FailureRatio*float64(b.requests)) {
+			changes = append(changes, g.setState(b, StateOpen, now))
+		}
+
+	case StateHalfOpen:
+		b.probes--
+		if failed {
+			changes = append(changes, g.setState(b, StateOpen, now))
+		} else if b.successes++; b.successes >= g.config.HalfOpenProbes {
+			changes = append(changes, g.setState(b, StateClosed, now))
+		}
+	}
+}
+
+func (g *group) setState(b *breaker, state State, now time.Time) change {
+	c := change{from: b.state, to: state}
+
+	b.gen++
+	b.state = state
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+	b.probes, b.successes = 0, 0
+
+	switch state {
+	case StateClosed:
+		b.expiry = now.Add(g.config.Window)
+	case StateOpen:
+		b.expiry = now.Add(g.config.OpenTimeout)
+	}
+
+	return c
+}
+
+// sweep evicts the idle closed breakers, which is done once per idle timeout at most.
+func (g *group) sweep(now time.Time) {
+	if now.Sub(g.swept) < g.config.IdleTimeout {
+		return
+	}
+
+	g.swept = now
+	for key, b := range g.breakers {
+		if b.state == StateClosed && now.Sub(b.used) >= g.config.IdleTimeout {
+			delete(g.breakers, key)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestBreaker(t *testing.T) {
+	var changes []string
+	config := Config{
+		ConsecutiveFailures: 3,
+		OpenTimeout:         20 * time.Millisecond,
+		IsFailure:           func(error) bool { return true },
+		OnStateChange: func(d driver.Driver, key string, from, to State) {
+			changes = append(changes, fmt.Sprintf("%s->%s", from, to))
+		},
+	}
+
+	var calls int
+	derr := errors.New("error")
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { calls++; return derr }, nil)
+	b := New(0, config, nil).Driver(d)
+	send := func() error { return b.Send(context.Background(), driver.Message{}) }
+
+	for i := 0; i < 3; i++ {
+		if err := send(); err != derr {
+			t.Fatalf("%d: expect the driver error, but got %v", i, err)
+		}
+	}
+
+	var berr Error
+	if err := send(); !errors.As(err, &berr) || berr.State != StateOpen {
+		t.Errorf("expect the open error, but got %v", err)
+	} else if calls != 3 {
+		t.Errorf("expect %d calls, but got %d", 3, calls)
+	}
+
+	// The probe fails, and the circuit is opened again.
+	time.Sleep(30 * time.Millisecond)
+	if err := send(); err != derr {
+		t.Errorf("expect the driver error, but got %v", err)
+	}
+	if err := send(); !errors.As(err, &berr) || berr.State != StateOpen {
+		t.Errorf("expect the open error, but got %v", err)
+	}
+
+	// The probe succeeds, and the circuit is closed.
+	time.Sleep(30 * time.Millisecond)
+	derr = nil
+	if err := send(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expect := []string{
+		"closed->open",
+		"open->half-open", "half-open->open",
+		"open->half-open", "half-open->closed",
+	}
+	if !reflect.DeepEqual(expect, changes) {
+		t.Errorf("expect the state changes %v, but got %v", expect, changes)
+	}
+}
+
+func TestBreakerPanic(t *testing.T) {
+	config := Config{
+		ConsecutiveFailures: 1,
+		OpenTimeout:         20 * time.Millisecond,
+		IsFailure:           func(error) bool { return false },
+	}
+
+	panicking := true
+	d := driver.New("test", "test", func(context.Context, driver.Message) error {
+		if panicking {
+			panic("panic")
+		}
+		return nil
+	}, nil)
+
+	b := New(0, config, nil).Driver(d)
+	send := func() (panicked bool, err error) {
+		defer func() { panicked = recover() != nil }()
+		return false, b.Send(context.Background(), driver.Message{})
+	}
+
+	var berr Error
+	if panicked, _ := send(); !panicked {
+		t.Errorf("expect to re-panic, but not")
+	}
+	if _, err := send(); !errors.As(err, &berr) || berr.State != StateOpen {
+		t.Errorf("expect the open error, but got %v", err)
+	}
+
+	// The panicked probe is released, and the circuit is opened again.
+	time.Sleep(30 * time.Millisecond)
+	if panicked, _ := send(); !panicked {
+		t.Errorf("expect to re-panic, but not")
+	}
+	if _, err := send(); !errors.As(err, &berr) || berr.State != StateOpen {
+		t.Errorf("expect the open error, but got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	panicking = false
+	if _, err := send(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
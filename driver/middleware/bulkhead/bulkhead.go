@@ -0,0 +1,255 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bulkhead provides a driver middleware to limit the number
+// of the messages being sent concurrently.
+package bulkhead
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+)
+
+// The reasons of the rejection.
+const (
+	ReasonFull      = "full"       // No capacity and not allowed to wait.
+	ReasonQueueFull = "queue full" // The wait queue is full.
+	ReasonTimeout   = "timeout"    // The context is done or MaxWait is exceeded while waiting.
+)
+
+// Error is returned when the message is rejected by the bulkhead.
+type Error struct {
+	Driver string // The name of the driver.
+	Key    string
+	Reason string
+	Err    error // The context error for ReasonTimeout.
+}
+
+// Error implements the interface error.
+func (e Error) Error() string {
+	return fmt.Sprintf("bulkhead: the message to the driver '%s' is rejected: %s", e.Driver, e.Reason)
+}
+
+// Unwrap returns the inner error.
+func (e Error) Unwrap() error { return e.Err }
+
+// Temporary returns true, because the capacity is released when the sending
+// messages finish, so the rejected message may be accepted by a later retry.
+func (e Error) Temporary() bool { return true }
+
+// Config is used to configure the bulkhead middleware.
+type Config struct {
+	// MaxConcurrent is the maximum total weight of the messages being sent
+	// concurrently in a driver or a key.
+	//
+	// Required.
+	MaxConcurrent int64
+
+	// Key returns the key of the message, and the messages with the same key
+	// share the capacity in the same driver, such as the receiver.
+	//
+	// Default: nil, that's, the capacity is shared per driver.
+	Key func(driver.Message) string
+
+	// Weight returns the weight of the message, such as the number of receivers,
+	// which is capped to MaxConcurrent.
+	//
+	// Default: 1
+	Weight func(driver.Message) int64
+
+	// MaxQueue is the maximum number of the messages waiting for the capacity.
+	// 0 means not to wait, and negative means no limit.
+	MaxQueue int
+
+	// MaxWait is the maximum duration to wait for the capacity
+	// besides the context. 0 means no limit.
+	MaxWait time.Duration
+
+	// OnReject is called when the message is rejected, which may be used
+	// to record the metrics.
+	OnReject func(d driver.Driver, m driver.Message, err Error)
+}
+
+// New returns a new bulkhead middleware, which limits the total weight
+// of the messages being sent concurrently by a weighted semaphore.
+//
+// When there is no capacity, the message waits in the FIFO queue
+// if MaxQueue is not 0, until it gets the capacity, or the context is done,
+// or MaxWait is exceeded. Or, it is rejected with Error.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.MaxConcurrent <= 0 {
+		panic("bulkhead: MaxConcurrent must be a positive")
+	}
+
+	return middleware.NewWithMatch("bulkhead", priority, matcher, func(d driver.Driver) driver.Driver {
+		g := &group{size: config.MaxConcurrent, sems: make(map[string]*semaphore, 4)}
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) error {
+			var key string
+			if config.Key != nil {
+				key = config.Key(m)
+			}
+
+			weight := int64(1)
+			if config.Weight != nil {
+				weight = min(max(config.Weight(m), 1), config.MaxConcurrent)
+			}
+
+			sem := g.get(key)
+			defer g.put(key)
+
+			if reason, err := sem.Acquire(c, weight, config.MaxQueue, config.MaxWait); reason != "" {
+				e := Error{Driver: d.Name(), Key: key, Reason: reason, Err: err}
+				if config.OnReject != nil {
+					config.OnReject(d, m, e)
+				}
+				return e
+			}
+			defer sem.Release(weight)
+
+			return d.Send(c, m)
+		})
+	})
+}
+
+type group struct {
+	size int64
+	lock sync.Mutex
+	sems map[string]*semaphore
+}
+
+// get returns the semaphore of the key, which must be put back by put.
+func (g *group) get(key string) *semaphore {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	s := g.sems[key]
+	if s == nil {
+		s = &semaphore{size: g.size}
+		g.sems[key] = s
+	}
+	s.refs++
+	return s
+}
+
+// put puts back the semaphore of the key, which is deleted if not used.
+func (g *group) put(key string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if s := g.sems[key]; s != nil {
+		if s.refs--; s.refs <= 0 {
+			delete(g.sems, key)
+		}
+	}
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// semaphore is a weighted semaphore with the FIFO wait queue.
+type semaphore struct {
+	refs int // Protected by the lock of group.
+
+	lock    sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List
+}
+
+// Acquire acquires the semaphore with the weight n,
+// and returns the reason if failing.
+func (s *semaphore) Acquire(c context.Context, n int64, maxQueue int, maxWait time.Duration) (reason string, err error) {
+	s.lock.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.lock.Unlock()
+		return "", nil
+	}
+
+	switch {
+	case maxQueue == 0:
+		s.lock.Unlock()
+		return ReasonFull, nil
+	case maxQueue > 0 && s.waiters.Len() >= maxQueue:
+		s.lock.Unlock()
+		return ReasonQueueFull, nil
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(waiter{n: n, ready: ready})
+	s.lock.Unlock()
+
+	if maxWait > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(c, maxWait)
+		defer cancel()
+	}
+
+	select {
+	case <-ready:
+		return "", nil
+
+	case <-c.Done():
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		select {
+		case <-ready:
+			// Acquired after the context is done, so use it.
+			return "", nil
+		default:
+		}
+
+		isFront := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		if isFront && s.size > s.cur {
+			s.notify() // The removed front may block the next waiters.
+		}
+		return ReasonTimeout, c.Err()
+	}
+}
+
+// Release releases the semaphore with the weight n.
+func (s *semaphore) Release(n int64) {
+	s.lock.Lock()
+	s.cur -= n
+	s.notify()
+	s.lock.Unlock()
+}
+
+func (s *semaphore) notify() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			break
+		}
+
+		w := front.Value.(waiter)
+		if s.size-s.cur < w.n {
+			break // Keep FIFO to avoid starving the heavy waiter.
+		}
+
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestBulkhead(t *testing.T) {
+	var lock sync.Mutex
+	var rejects []string
+	config := Config{
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+		OnReject: func(d driver.Driver, m driver.Message, err Error) {
+			lock.Lock()
+			rejects = append(rejects, err.Reason)
+			lock.Unlock()
+		},
+	}
+
+	block := make(chan struct{})
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { <-block; return nil }, nil)
+	b := New(0, config, nil).Driver(d)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.Send(context.Background(), driver.Message{})
+		}(i)
+		time.Sleep(10 * time.Millisecond) // One is sending, and the other is waiting.
+	}
+
+	var berr Error
+	if err := b.Send(context.Background(), driver.Message{}); !errors.As(err, &berr) || berr.Reason != ReasonQueueFull {
+		t.Errorf("expect the queue full error, but got %v", err)
+	}
+
+	close(block)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+	}
+
+	// Timeout while waiting.
+	block = make(chan struct{})
+	b = New(0, config, nil).Driver(d)
+	go func() { _ = b.Send(context.Background(), driver.Message{}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Send(ctx, driver.Message{}); !errors.As(err, &berr) || berr.Reason != ReasonTimeout ||
+		!errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect the timeout error, but got %v", err)
+	}
+	close(block)
+
+	if len(rejects) != 2 || rejects[0] != ReasonQueueFull || rejects[1] != ReasonTimeout {
+		t.Errorf("unexpected rejects: %v", rejects)
+	}
+}
+
+func TestSemaphoreFIFO(t *testing.T) {
+	s := &semaphore{size: 3}
+	ctx := context.Background()
+
+	_, _ = s.Acquire(ctx, 2, -1, 0)
+	done := make(chan int64, 2)
+	go func() { _, _ = s.Acquire(ctx, 3, -1, 0); done <- 3 }()
+	time.Sleep(10 * time.Millisecond)
+	go func() { _, _ = s.Acquire(ctx, 1, -1, 0); done <- 1 }()
+	time.Sleep(10 * time.Millisecond)
+
+	// The light waiter cannot jump over the heavy one.
+	select {
+	case n := <-done:
+		t.Fatalf("unexpected acquired weight %d", n)
+	default:
+	}
+
+	s.Release(2)
+	if n := <-done; n != 3 {
+		t.Errorf("expect the weight %d acquired first, but got %d", 3, n)
+	}
+	s.Release(3)
+	if n := <-done; n != 1 {
+		t.Errorf("expect the weight %d acquired, but got %d", 1, n)
+	}
+}
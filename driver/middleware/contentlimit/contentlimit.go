@@ -0,0 +1,373 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contentlimit provides a driver middleware to enforce the length
+// limit of the message content by truncating, splitting or rejecting it.
+package contentlimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+)
+
+// Mode is the mode to handle the content exceeding the limit.
+type Mode int
+
+const (
+	// ModeTruncate truncates the content and appends the marker.
+	ModeTruncate Mode = iota
+
+	// ModeSplit splits the content into the numbered parts,
+	// which are sent in order.
+	ModeSplit
+
+	// ModeReject rejects the message with Error.
+	ModeReject
+)
+
+// Error is returned when the content exceeds the limit in ModeReject.
+type Error struct {
+	Type   string // The driver type.
+	Length int
+	Max    int
+}
+
+// Error implements the interface error.
+func (e Error) Error() string {
+	return fmt.Sprintf("contentlimit: the content length %d exceeds the limit %d of the driver type '%s'",
+		e.Length, e.Max, e.Type)
+}
+
+// Limit is the length limit of the content.
+//
+// For example,
+//
+//	// The text of the feishu webhook, which limits the request body to 20KB.
+//	Limit{Max: 18 * 1024, Bytes: true, Mode: ModeSplit}
+//
+//	// The SMS segment, which has 160 GSM-7 characters or 70 UCS-2 characters.
+//	Limit{Max: 160, UCS2Max: 70, Mode: ModeSplit}
+type Limit struct {
+	// Max is the maximum length of the content, which is measured in runes,
+	// or in the bytes of UTF-8 if Bytes is true.
+	//
+	// Required.
+	Max   int
+	Bytes bool
+
+	// UCS2Max is the maximum length in UTF-16 code units used instead of Max
+	// if the content contains any character out of the GSM-7 charset,
+	// such as the SMS, that's, the characters out of the BMP, such as emoji,
+	// count 2. If set, the content of the GSM-7 charset is measured in septets,
+	// that's, the characters in the extension table count 2.
+	//
+	// Default: 0, that's, disabled.
+	UCS2Max int
+
+	// Mode is the mode to handle the content exceeding the limit.
+	//
+	// Default: ModeTruncate
+	Mode Mode
+
+	// Marker is appended to the truncated content by ModeTruncate,
+	// and must be shorter than Max and UCS2Max if set.
+	//
+	// Default: "..."
+	Marker string
+
+	// PartFormat is the format of the part number, such as "(1/3) ",
+	// which is the prefix of the text content or the title of the feishu post
+	// by ModeSplit, and whose arguments are the part index from 1 and the total.
+	//
+	// Default: "(%d/%d) "
+	PartFormat string
+}
+
+// Config is used to configure the content limit middleware.
+type Config struct {
+	// Limits is the limits by the driver type.
+	// The driver without the limit of its type is not wrapped.
+	//
+	// Required.
+	Limits map[string]Limit
+}
+
+// New returns a new content limit middleware, which enforces the limit
+// of the driver type on the message content.
+//
+// The supported contents are as follow, and the others are sent as they are.
+//
+//	string
+//	map[string]any: the feishu post if the metadata "MsgType" is "post"
+//
+// For the feishu post, only the title and the texts of the elements
+// in the paragraphs are measured, and each language is handled separately.
+// It is split between the paragraphs, or the elements if a paragraph exceeds
+// the limit, or the text if an element exceeds the limit. When the post
+// exceeds the limit, the title longer than the half of the limit is truncated
+// with the marker, which is repeated in each part by ModeSplit.
+//
+// By ModeTruncate and ModeSplit, the marker and the part format are also
+// measured, so the SMS content becomes UCS-2 if they are out of GSM-7.
+//
+// By ModeSplit, the parts are sent in order with the same context,
+// and it stops and returns the error when failing to send a part.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	limits := make(map[string]Limit, len(config.Limits))
+	for dtype, limit := range config.Limits {
+		if limit.Max <= 0 || limit.UCS2Max < 0 {
+			panic(fmt.Errorf("contentlimit: invalid limit %+v of the driver type '%s'", limit, dtype))
+		}
+		if limit.Marker == "" {
+			limit.Marker = "..."
+		}
+		if limit.Mode == ModeTruncate && !limit.fitMarker() {
+			panic(fmt.Errorf("contentlimit: the marker '%s' is too long for the limit %+v of the driver type '%s'",
+				limit.Marker, limit, dtype))
+		}
+		if limit.PartFormat == "" {
+			limit.PartFormat = "(%d/%d) "
+		}
+		limits[dtype] = limit
+	}
+
+	return middleware.NewWithMatch("contentlimit", priority, matcher, func(d driver.Driver) driver.Driver {
+		limit, ok := limits[d.Type()]
+		if !ok {
+			return d
+		}
+
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
+			var contents []any
+			switch content := m.Content.(type) {
+			case string:
+				contents, err = limit.text(content)
+
+			case map[string]any:
+				if msgtype, _ := m.Metadata["MsgType"].(string); msgtype == "post" {
+					contents, err = limit.post(content)
+				}
+			}
+
+			switch {
+			case err != nil:
+				if e, ok := err.(Error); ok {
+					e.Type = d.Type()
+					err = e
+				}
+				return
+
+			case len(contents) == 0:
+				return d.Send(c, m)
+
+			case len(contents) == 1:
+				m.Content = contents[0]
+				return d.Send(c, m)
+			}
+
+			for i, content := range contents {
+				m.Content = content
+				if err = d.Send(c, m); err != nil {
+					return fmt.Errorf("contentlimit: fail to send the part %d/%d: %w", i+1, len(contents), err)
+				}
+			}
+			return
+		})
+	})
+}
+
+/// ----------------------------------------------------------------------- ///
+
+// measure is used to measure the length of the content.
+type measure struct {
+	max   int
+	width func(rune) int
+}
+
+func (m measure) len(s string) (n int) {
+	for _, r := range s {
+		n += m.width(r)
+	}
+	return
+}
+
+func runewidth(rune) int { return 1 }
+
+func ucs2width(r rune) int {
+	if r > 0xFFFF { // A surrogate pair
+		return 2
+	}
+	return 1
+}
+
+func gsm7width(r rune) int {
+	if strings.ContainsRune(gsm7ext, r) {
+		return 2
+	}
+	return 1
+}
+
+// measure returns the measure of the content by its all texts.
+func (l Limit) measure(texts ...string) measure {
+	if l.Bytes {
+		return measure{max: l.Max, width: utf8.RuneLen}
+	}
+
+	if l.UCS2Max > 0 {
+		for _, s := range texts {
+			if !isGSM7(s) {
+				return measure{max: l.UCS2Max, width: ucs2width}
+			}
+		}
+		return measure{max: l.Max, width: gsm7width}
+	}
+
+	return measure{max: l.Max, width: runewidth}
+}
+
+// fitMarker reports whether the marker is shorter than the maximum length
+// by any measure.
+func (l Limit) fitMarker() bool {
+	if l.Bytes {
+		return len(l.Marker) < l.Max
+	}
+
+	if l.UCS2Max > 0 {
+		if (measure{width: ucs2width}).len(l.Marker) >= l.UCS2Max {
+			return false
+		}
+		if isGSM7(l.Marker) {
+			return (measure{width: gsm7width}).len(l.Marker) < l.Max
+		}
+		return true
+	}
+
+	return utf8.RuneCountInString(l.Marker) < l.Max
+}
+
+// modeMeasure is the same as measure, but also measures the marker
+// or the part format added by the mode, which may be out of the GSM-7 charset.
+func (l Limit) modeMeasure(texts ...string) measure {
+	switch l.Mode {
+	case ModeTruncate:
+		texts = append(texts, l.Marker)
+	case ModeSplit:
+		texts = append(texts, l.PartFormat)
+	}
+	return l.measure(texts...)
+}
+
+const (
+	gsm7basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7ext = "\f^{}\\[~]|€"
+)
+
+func isGSM7(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(gsm7basic, r) && !strings.ContainsRune(gsm7ext, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// cut returns the longest prefix of s whose length is not greater than max.
+func (m measure) cut(s string, max int) string {
+	var n int
+	for i, r := range s {
+		if n += m.width(r); n > max {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// split splits s into the chunks, each of which is not longer than max,
+// and prefers to split at the newline in the second half of the chunk,
+// which is dropped.
+func (m measure) split(s string, max int) (chunks []string) {
+	for s != "" {
+		chunk := m.cut(s, max)
+		if len(chunk) == len(s) {
+			chunks = append(chunks, s)
+			break
+		}
+
+		if chunk == "" { // max is less than the width of the first rune.
+			_, size := utf8.DecodeRuneInString(s)
+			chunk = s[:size]
+		}
+
+		s = s[len(chunk):]
+		if s[0] == '\n' {
+			s = s[1:]
+		} else if i := strings.LastIndexByte(chunk, '\n'); i >= len(chunk)/2 {
+			chunk, s = chunk[:i], chunk[i+1:]+s
+		}
+
+		chunks = append(chunks, chunk)
+	}
+	return
+}
+
+func (l Limit) part(i, n int) string { return fmt.Sprintf(l.PartFormat, i, n) }
+
+func (l Limit) text(s string) (contents []any, err error) {
+	m := l.measure(s)
+	length := m.len(s)
+	if length <= m.max {
+		return
+	}
+
+	switch l.Mode {
+	case ModeReject:
+		err = Error{Length: length, Max: m.max}
+
+	case ModeSplit:
+		if m = l.modeMeasure(s); m.len(s) <= m.max {
+			return
+		}
+
+		// Reserve the length of the part number, which depends on the total.
+		for total := 2; ; {
+			reserve := m.len(l.part(total, total))
+			chunks := m.split(s, max(m.max-reserve, 1))
+			if len(chunks) > total && m.len(l.part(len(chunks), len(chunks))) > reserve {
+				total = len(chunks)
+				continue
+			}
+
+			contents = make([]any, len(chunks))
+			for i, chunk := range chunks {
+				contents[i] = l.part(i+1, len(chunks)) + chunk
+			}
+			return
+		}
+
+	default:
+		if m = l.modeMeasure(s); m.len(s) <= m.max {
+			return
+		}
+		s = m.cut(s, max(m.max-m.len(l.Marker), 0)) + l.Marker
+		contents = []any{s}
+	}
+
+	return
+}
@@ -0,0 +1,199 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contentlimit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestText(t *testing.T) {
+	mw := New(0, Config{Limits: map[string]Limit{
+		"truncate":  {Max: 8},
+		"split":     {Max: 10, Mode: ModeSplit},
+		"reject":    {Max: 8, Bytes: true, Mode: ModeReject},
+		"sms":       {Max: 8, UCS2Max: 4, Mode: ModeSplit, PartFormat: "%d/%d:"},
+		"ucs2":      {Max: 8, UCS2Max: 4, Mode: ModeReject},
+		"smsmarker": {Max: 8, UCS2Max: 2, Marker: "…"},
+		"smspart":   {Max: 20, UCS2Max: 10, Mode: ModeSplit, PartFormat: "【%d/%d】"},
+	}}, nil)
+
+	send := func(dtype, content string) (contents []any, err error) {
+		d := driver.New("test", dtype, func(_ context.Context, m driver.Message) error {
+			contents = append(contents, m.Content)
+			return nil
+		}, nil)
+		err = mw.Driver(d).Send(context.Background(), driver.Message{Content: content})
+		return
+	}
+
+	if contents, _ := send("truncate", "你好世界你好世界你好"); !reflect.DeepEqual(contents, []any{"你好世界你..."}) {
+		t.Errorf("unexpected truncated contents: %v", contents)
+	}
+
+	if contents, _ := send("split", "abcd\nefghijk\nlmn"); !reflect.DeepEqual(contents, []any{"(1/4) abcd", "(2/4) efgh", "(3/4) ijk", "(4/4) lmn"}) {
+		t.Errorf("unexpected split contents: %q", contents)
+	}
+
+	if _, err := send("reject", "你好世界"); !errors.As(err, new(Error)) {
+		t.Errorf("expect a content limit error, but got %v", err)
+	}
+
+	if contents, _ := send("sms", "ab{}cde"); !reflect.DeepEqual(contents, []any{"1/3:ab{", "2/3:}cd", "3/3:e"}) {
+		t.Errorf("unexpected gsm7 contents: %q", contents)
+	}
+	if contents, _ := send("sms", "你好"); !reflect.DeepEqual(contents, []any{"你好"}) {
+		t.Errorf("unexpected ucs2 contents: %q", contents)
+	}
+
+	// The marker out of the GSM-7 charset makes the content UCS-2.
+	if contents, _ := send("smsmarker", strings.Repeat("a", 10)); !reflect.DeepEqual(contents, []any{"a…"}) {
+		t.Errorf("unexpected ucs2 truncated contents: %q", contents)
+	}
+	if contents, _ := send("smsmarker", strings.Repeat("a", 8)); !reflect.DeepEqual(contents, []any{"aaaaaaaa"}) {
+		t.Errorf("unexpected gsm7 contents: %q", contents)
+	}
+	if contents, _ := send("smspart", strings.Repeat("a", 25)); len(contents) != 5 || contents[4] != "【5/5】aaaaa" {
+		t.Errorf("unexpected ucs2 split contents: %q", contents)
+	}
+
+	// The characters out of the BMP count 2 in UCS-2.
+	if contents, err := send("ucs2", "你好😀"); err != nil || !reflect.DeepEqual(contents, []any{"你好😀"}) {
+		t.Errorf("unexpected ucs2 contents: %q, %v", contents, err)
+	}
+	var lerr Error
+	if _, err := send("ucs2", "你好😀😀"); !errors.As(err, &lerr) {
+		t.Errorf("expect a content limit error, but got %v", err)
+	} else if lerr.Length != 6 || lerr.Max != 4 {
+		t.Errorf("unexpected content limit error: %+v", lerr)
+	}
+}
+
+func TestMarker(t *testing.T) {
+	for _, limit := range []Limit{
+		{Max: 3},
+		{Max: 3, Bytes: true, Marker: "…"},
+		{Max: 8, UCS2Max: 3},
+		{Max: 2, UCS2Max: 8, Marker: "{"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expect a panic for the limit %+v", limit)
+				}
+			}()
+			New(0, Config{Limits: map[string]Limit{"test": limit}}, nil)
+		}()
+	}
+
+	// The marker is not used by the other modes.
+	New(0, Config{Limits: map[string]Limit{"test": {Max: 3, Mode: ModeSplit}}}, nil)
+}
+
+func TestPost(t *testing.T) {
+	text := func(s string) map[string]any { return map[string]any{"tag": "text", "text": s} }
+	post := map[string]any{"en_us": map[string]any{
+		"title": "t",
+		"content": []any{
+			[]any{text("abc"), text("def")},
+			[]any{text("gh")},
+			[]any{text(strings.Repeat("x", 12))},
+		},
+	}}
+
+	var contents []any
+	d := driver.New("test", "feishu.webhook", func(_ context.Context, m driver.Message) error {
+		contents = append(contents, m.Content)
+		return nil
+	}, nil)
+
+	// The title with the part number takes 7 of 15.
+	mw := New(0, Config{Limits: map[string]Limit{"feishu.webhook": {Max: 15, Mode: ModeSplit}}}, nil)
+	err := mw.Driver(d).Send(context.Background(), driver.Message{Content: post, Metadata: map[string]any{"MsgType": "post"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expects := []any{
+		map[string]any{"en_us": map[string]any{"title": "(1/3) t", "content": []any{
+			[]any{text("abc"), text("def")},
+			[]any{text("gh")},
+		}}},
+		map[string]any{"en_us": map[string]any{"title": "(2/3) t", "content": []any{
+			[]any{text("xxxxxxxx")},
+		}}},
+		map[string]any{"en_us": map[string]any{"title": "(3/3) t", "content": []any{
+			[]any{text("xxxx")},
+		}}},
+	}
+	if !reflect.DeepEqual(contents, expects) {
+		t.Errorf("expect %v, but got %v", expects, contents)
+	}
+
+	// The title is counted in the length, and truncated if it is too long.
+	for _, c := range []struct {
+		limit   Limit
+		title   string
+		content []any
+		expects []any
+	}{
+		{
+			limit:   Limit{Max: 8},
+			title:   "title",
+			content: []any{[]any{text("abcd")}},
+			expects: []any{map[string]any{"en_us": map[string]any{"title": "ti", "content": []any{
+				[]any{text("abc"), text("...")},
+			}}}},
+		},
+		{
+			limit:   Limit{Max: 12},
+			title:   strings.Repeat("t", 12),
+			content: []any{[]any{text("ab")}},
+			expects: []any{map[string]any{"en_us": map[string]any{"title": "t...", "content": []any{
+				[]any{text("ab")},
+			}}}},
+		},
+		{
+			limit:   Limit{Max: 20, Mode: ModeSplit},
+			title:   strings.Repeat("t", 20),
+			content: []any{[]any{text("abcdefgh")}},
+			expects: []any{
+				map[string]any{"en_us": map[string]any{"title": "(1/2) tttt...", "content": []any{
+					[]any{text("abcdefg")},
+				}}},
+				map[string]any{"en_us": map[string]any{"title": "(2/2) tttt...", "content": []any{
+					[]any{text("h")},
+				}}},
+			},
+		},
+	} {
+		contents = nil
+		mw = New(0, Config{Limits: map[string]Limit{"feishu.webhook": c.limit}}, nil)
+		post = map[string]any{"en_us": map[string]any{"title": c.title, "content": c.content}}
+		err = mw.Driver(d).Send(context.Background(), driver.Message{Content: post, Metadata: map[string]any{"MsgType": "post"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(contents, c.expects) {
+			t.Errorf("expect %v, but got %v", c.expects, contents)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contentlimit
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// langPost is the feishu post of a language, such as "zh_cn".
+type langPost struct {
+	raw        map[string]any
+	title      string
+	paragraphs [][]any
+}
+
+func (p langPost) with(title string, paragraphs [][]any) map[string]any {
+	content := make([]any, len(paragraphs))
+	for i, p := range paragraphs {
+		content[i] = p
+	}
+
+	post := maps.Clone(p.raw)
+	post["title"] = title
+	post["content"] = content
+	return post
+}
+
+func decodeLangPost(v any) (p langPost, err error) {
+	var ok bool
+	if p.raw, ok = v.(map[string]any); !ok {
+		err = fmt.Errorf("contentlimit: expect the post content of a language is a map[string]any, but got %T", v)
+		return
+	}
+
+	p.title, _ = p.raw["title"].(string)
+	switch content := p.raw["content"].(type) {
+	case nil:
+	case []any:
+		p.paragraphs = make([][]any, len(content))
+		for i, paragraph := range content {
+			if p.paragraphs[i], err = decodeParagraph(paragraph); err != nil {
+				return
+			}
+		}
+	case [][]any:
+		p.paragraphs = content
+	case [][]map[string]any:
+		p.paragraphs = make([][]any, len(content))
+		for i, paragraph := range content {
+			p.paragraphs[i], _ = decodeParagraph(paragraph)
+		}
+	default:
+		err = fmt.Errorf("contentlimit: unsupported post paragraphs %T", content)
+	}
+	return
+}
+
+func decodeParagraph(v any) ([]any, error) {
+	switch paragraph := v.(type) {
+	case []any:
+		return paragraph, nil
+	case []map[string]any:
+		elements := make([]any, len(paragraph))
+		for i, e := range paragraph {
+			elements[i] = e
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("contentlimit: unsupported post paragraph %T", v)
+	}
+}
+
+func elementText(e any) string {
+	if m, ok := e.(map[string]any); ok {
+		text, _ := m["text"].(string)
+		return text
+	}
+	return ""
+}
+
+func (m measure) paragraphLen(paragraph []any) (n int) {
+	for _, e := range paragraph {
+		n += m.len(elementText(e))
+	}
+	return
+}
+
+// splitElement splits the text of the element if it is longer than max.
+func (m measure) splitElement(e any, max int) []any {
+	text := elementText(e)
+	if m.len(text) <= max {
+		return []any{e}
+	}
+
+	chunks := m.split(text, max)
+	elements := make([]any, len(chunks))
+	for i, chunk := range chunks {
+		element := maps.Clone(e.(map[string]any))
+		element["text"] = chunk
+		elements[i] = element
+	}
+	return elements
+}
+
+// splitPost splits the paragraphs into the parts, each of which is not
+// longer than max, between the paragraphs, or the elements if a paragraph
+// is longer than max.
+func (m measure) splitPost(paragraphs [][]any, max int) (parts [][][]any) {
+	var cur [][]any
+	var curlen int
+	flush := func() {
+		if len(cur) > 0 {
+			parts = append(parts, cur)
+			cur, curlen = nil, 0
+		}
+	}
+
+	for _, paragraph := range paragraphs {
+		plen := m.paragraphLen(paragraph)
+		if curlen+plen <= max {
+			cur = append(cur, paragraph)
+			curlen += plen
+			continue
+		}
+
+		flush()
+		if plen <= max {
+			cur, curlen = [][]any{paragraph}, plen
+			continue
+		}
+
+		// The paragraph is too long, so split it between the elements.
+		var sub []any
+		var sublen int
+		for _, e := range paragraph {
+			for _, e := range m.splitElement(e, max) {
+				elen := m.len(elementText(e))
+				if sublen+elen > max && len(sub) > 0 {
+					parts = append(parts, [][]any{sub})
+					sub, sublen = nil, 0
+				}
+				sub = append(sub, e)
+				sublen += elen
+			}
+		}
+		cur, curlen = [][]any{sub}, sublen
+	}
+
+	flush()
+	return
+}
+
+// cutTitle truncates the title with the marker if it is longer than
+// the half of size, so that the title does not take the room of the paragraphs.
+func (l Limit) cutTitle(m measure, title string, size int) string {
+	half := size / 2
+	if m.len(title) <= half {
+		return title
+	}
+
+	if marker := m.len(l.Marker); marker < half {
+		return m.cut(title, half-marker) + l.Marker
+	}
+	return m.cut(title, half)
+}
+
+func (l Limit) post(post map[string]any) (contents []any, err error) {
+	langs := make([]string, 0, len(post))
+	posts := make(map[string]langPost, len(post))
+	var texts []string
+	for lang, v := range post {
+		p, err := decodeLangPost(v)
+		if err != nil {
+			return nil, err
+		}
+
+		texts = append(texts, p.title)
+		for _, paragraph := range p.paragraphs {
+			for _, e := range paragraph {
+				texts = append(texts, elementText(e))
+			}
+		}
+
+		langs = append(langs, lang)
+		posts[lang] = p
+	}
+	slices.Sort(langs)
+
+	m := l.measure(texts...)
+	postlen := func(p langPost) (n int) {
+		n = m.len(p.title)
+		for _, paragraph := range p.paragraphs {
+			n += m.paragraphLen(paragraph)
+		}
+		return
+	}
+
+	var length int
+	for _, p := range posts {
+		length = max(length, postlen(p))
+	}
+	if length <= m.max {
+		return
+	}
+
+	switch l.Mode {
+	case ModeReject:
+		err = Error{Length: length, Max: m.max}
+
+	case ModeSplit:
+		m = l.modeMeasure(texts...)
+
+		// Reserve the length of the part number in the title,
+		// which depends on the total.
+		var total int
+		titles := make(map[string]string, len(langs))
+		parts := make(map[string][][][]any, len(langs))
+		for reserve := m.len(l.part(2, 2)); ; {
+			total = 0
+			for _, lang := range langs {
+				p := posts[lang]
+				titles[lang] = l.cutTitle(m, p.title, m.max-reserve)
+				parts[lang] = m.splitPost(p.paragraphs, max(m.max-reserve-m.len(titles[lang]), 1))
+				total = max(total, len(parts[lang]))
+			}
+
+			if n := m.len(l.part(total, total)); n > reserve {
+				reserve = n
+				continue
+			}
+			break
+		}
+
+		contents = make([]any, total)
+		for i := range total {
+			content := make(map[string]any, len(langs))
+			for _, lang := range langs {
+				if i < len(parts[lang]) {
+					content[lang] = posts[lang].with(l.part(i+1, total)+titles[lang], parts[lang][i])
+				}
+			}
+			contents[i] = content
+		}
+
+	default:
+		m = l.modeMeasure(texts...)
+		marker := map[string]any{"tag": "text", "text": l.Marker}
+		content := make(map[string]any, len(langs))
+		for _, lang := range langs {
+			p := posts[lang]
+			if postlen(p) <= m.max {
+				content[lang] = p.raw
+				continue
+			}
+
+			title := l.cutTitle(m, p.title, m.max-m.len(l.Marker))
+			parts := m.splitPost(p.paragraphs, max(m.max-m.len(l.Marker)-m.len(title), 1))
+			if len(parts) <= 1 { // Only the title is truncated.
+				content[lang] = p.with(title, p.paragraphs)
+				continue
+			}
+
+			paragraphs := parts[0]
+			last := len(paragraphs) - 1
+			paragraphs[last] = append(slices.Clip(paragraphs[last]), marker)
+			content[lang] = p.with(title, paragraphs)
+		}
+		contents = []any{content}
+	}
+
+	return
+}
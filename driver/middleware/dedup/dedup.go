@@ -0,0 +1,199 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup provides a driver middleware to suppress the identical
+// messages within a time window.
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+	"github.com/xgfone/go-toolkit/jsonx"
+)
+
+// Store is used to store the keys of the sent messages.
+//
+// For the multi-instance deployments, it may be implemented
+// by a shared backend, such as the redis command "SET key 1 NX PX ttl".
+type Store interface {
+	// Add adds the key with the ttl if it does not exist or has expired,
+	// and reports whether it is added.
+	Add(c context.Context, key string, ttl time.Duration) (added bool, err error)
+
+	// Delete deletes the key, which is used to allow to send the message
+	// again when it fails to be sent.
+	Delete(c context.Context, key string) error
+}
+
+// Config is used to configure the deduplication middleware.
+type Config struct {
+	// TTL is the time window in which the identical messages are suppressed.
+	//
+	// Default: 5m
+	TTL time.Duration
+
+	// Key returns the key of the message to identify the identical messages.
+	// If it returns "", the message is not deduplicated.
+	//
+	// Default: DefaultKey()
+	Key func(driver.Message) string
+
+	// Store is used to store the keys of the sent messages.
+	//
+	// Default: NewMemoryStore(10000)
+	Store Store
+
+	// OnDuplicate is called when the message is suppressed.
+	OnDuplicate func(c context.Context, m driver.Message)
+}
+
+// DefaultKey returns a key function, which returns the hash of the channel,
+// receiver, content and the values of the given metadata keys of the message.
+//
+// If the content or metadata cannot be encoded by JSON, return "".
+func DefaultKey(metadataKeys ...string) func(driver.Message) string {
+	return func(m driver.Message) string {
+		type Key struct {
+			Name     string
+			Receiver string
+			Content  any
+			Metadata map[string]any `json:",omitempty"`
+		}
+
+		key := Key{Name: m.Name, Receiver: m.Receiver, Content: m.Content}
+		for _, k := range metadataKeys {
+			if v, ok := m.Metadata[k]; ok {
+				if key.Metadata == nil {
+					key.Metadata = make(map[string]any, len(metadataKeys))
+				}
+				key.Metadata[k] = v
+			}
+		}
+
+		data, err := jsonx.Marshal(key)
+		if err != nil {
+			return ""
+		}
+
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+}
+
+// New returns a new deduplication middleware, which suppresses the identical
+// messages with the same key within TTL, and returns nil for them.
+//
+// If the identical message is still being sent, the duplicate waits for it
+// and returns its result instead. But the messages in flight are only tracked
+// by the middleware itself, so, for the shared store, the duplicate of the
+// message being sent by another instance still returns nil immediately.
+//
+// If the message fails to be sent, its key is deleted from the store so that
+// it can be sent again. If the store fails, the message is sent anyway.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+	if config.Key == nil {
+		config.Key = DefaultKey()
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore(10000)
+	}
+
+	flights := &flights{calls: make(map[string]*flight, 16)}
+	return middleware.NewWithMatch("dedup", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
+			key := config.Key(m)
+			if key == "" {
+				return d.Send(c, m)
+			}
+
+			f, leader := flights.start(key)
+			if !leader {
+				if config.OnDuplicate != nil {
+					config.OnDuplicate(c, m)
+				}
+				return f.wait(c)
+			}
+			defer flights.finish(key, f)
+
+			added, serr := config.Store.Add(c, key, config.TTL)
+			if serr == nil && !added {
+				f.err = nil
+				if config.OnDuplicate != nil {
+					config.OnDuplicate(c, m)
+				}
+				return nil
+			}
+
+			if err = d.Send(c, m); err != nil && added {
+				_ = config.Store.Delete(context.WithoutCancel(c), key)
+			}
+			f.err = err
+			return
+		})
+	})
+}
+
+var errAborted = errors.New("dedup: the identical message in flight is aborted")
+
+// flight is the message in flight, whose duplicates wait for its result.
+type flight struct {
+	done chan struct{}
+	err  error
+}
+
+func (f *flight) wait(c context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+type flights struct {
+	lock  sync.Mutex
+	calls map[string]*flight
+}
+
+// start returns the flight of the key, and reports whether it is a new one.
+func (fs *flights) start(key string) (f *flight, leader bool) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if f = fs.calls[key]; f != nil {
+		return f, false
+	}
+
+	// err is overwritten when finished, or the sending panics.
+	f = &flight{done: make(chan struct{}), err: errAborted}
+	fs.calls[key] = f
+	return f, true
+}
+
+func (fs *flights) finish(key string, f *flight) {
+	fs.lock.Lock()
+	delete(fs.calls, key)
+	fs.lock.Unlock()
+	close(f.done)
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestDedup(t *testing.T) {
+	var duplicates int
+	config := Config{
+		TTL: 20 * time.Millisecond,
+		Key: DefaultKey("Level"),
+
+		OnDuplicate: func(context.Context, driver.Message) { duplicates++ },
+	}
+
+	var calls int
+	var derr error
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { calls++; return derr }, nil)
+	s := New(0, config, nil).Driver(d)
+	send := func(content string, level int) error {
+		m := driver.NewMessage("channel", "test", "receiver", content, map[string]any{"Level": level, "Trace": content})
+		return s.Send(context.Background(), m)
+	}
+
+	_ = send("a", 1)
+	_ = send("a", 1)
+	_ = send("a", 2)
+	_ = send("b", 1)
+	if calls != 3 || duplicates != 1 {
+		t.Errorf("expect %d calls and %d duplicates, but got %d and %d", 3, 1, calls, duplicates)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	_ = send("a", 1)
+	if calls != 4 {
+		t.Errorf("expect %d calls, but got %d", 4, calls)
+	}
+
+	// The failed message can be sent again.
+	derr = errors.New("error")
+	_ = send("c", 1)
+	_ = send("c", 1)
+	if calls != 6 {
+		t.Errorf("expect %d calls, but got %d", 6, calls)
+	}
+}
+
+func TestDedupInFlight(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan error)
+	d := driver.New("test", "test", func(context.Context, driver.Message) error {
+		calls.Add(1)
+		close(started)
+		return <-release
+	}, nil)
+
+	duplicates := make(chan struct{}, 2)
+	config := Config{OnDuplicate: func(context.Context, driver.Message) { duplicates <- struct{}{} }}
+	s := New(0, config, nil).Driver(d)
+	m := driver.NewMessage("channel", "test", "receiver", "content", nil)
+
+	results := make(chan error, 2)
+	go func() { results <- s.Send(context.Background(), m) }()
+	<-started
+
+	// The duplicate in flight gives up when the context is done.
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Send(c, m); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect the error context.DeadlineExceeded, but got %v", err)
+	}
+
+	// The duplicate in flight waits for the result of the original.
+	go func() { results <- s.Send(context.Background(), m) }()
+	<-duplicates
+	<-duplicates
+
+	release <- errors.New("error")
+	for range 2 {
+		if err := <-results; err == nil || err.Error() != "error" {
+			t.Errorf("expect the error of the original, but got %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expect %d calls, but got %d", 1, n)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(2)
+	ctx := context.Background()
+
+	_, _ = s.Add(ctx, "a", time.Minute)
+	_, _ = s.Add(ctx, "b", time.Minute)
+	_, _ = s.Add(ctx, "c", time.Minute)
+	if n := s.Len(); n != 2 {
+		t.Errorf("expect %d keys, but got %d", 2, n)
+	}
+
+	if added, _ := s.Add(ctx, "a", time.Minute); !added {
+		t.Errorf("expect the evicted key 'a' to be added, but not")
+	}
+	if added, _ := s.Add(ctx, "a", time.Minute); added {
+		t.Errorf("expect the key 'a' not to be added, but added")
+	}
+
+	// The hit key 'c' is used recently, so 'a' is evicted instead of it.
+	if added, _ := s.Add(ctx, "c", time.Minute); added {
+		t.Errorf("expect the key 'c' not to be added, but added")
+	}
+	_, _ = s.Add(ctx, "d", time.Minute)
+	if added, _ := s.Add(ctx, "c", time.Minute); added {
+		t.Errorf("expect the key 'c' not to be evicted, but evicted")
+	}
+	if added, _ := s.Add(ctx, "a", time.Minute); !added {
+		t.Errorf("expect the key 'a' to be evicted, but not")
+	}
+
+	// The expired keys are evicted before the least recently used one.
+	s = NewMemoryStore(3)
+	_, _ = s.Add(ctx, "a", time.Millisecond)
+	_, _ = s.Add(ctx, "b", time.Minute)
+	time.Sleep(2 * time.Millisecond)
+	_, _ = s.Add(ctx, "c", time.Minute)
+	if n := s.Len(); n != 2 {
+		t.Errorf("expect %d keys, but got %d", 2, n)
+	}
+	if added, _ := s.Add(ctx, "b", time.Minute); added {
+		t.Errorf("expect the key 'b' not to be evicted, but evicted")
+	}
+}
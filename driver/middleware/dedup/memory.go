@@ -0,0 +1,106 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = new(MemoryStore)
+
+// MemoryStore is an in-memory store with the LRU eviction,
+// where a key is used when it is added or hit as a duplicate.
+type MemoryStore struct {
+	lock  sync.Mutex
+	cap   int
+	list  *list.List // The front is the most recently used.
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key    string
+	expiry time.Time
+}
+
+// NewMemoryStore returns a new in-memory store, which holds at most capacity keys.
+// When it is full, the expired keys at the back and the least recently used
+// key are evicted.
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		panic("dedup.NewMemoryStore: capacity must be a positive")
+	}
+
+	return &MemoryStore{
+		cap:   capacity,
+		list:  list.New(),
+		items: make(map[string]*list.Element, min(capacity, 1024)),
+	}
+}
+
+// Len returns the number of the keys, including the expired ones not evicted.
+func (s *MemoryStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.list.Len()
+}
+
+// Add implements the interface Store.
+func (s *MemoryStore) Add(_ context.Context, key string, ttl time.Duration) (added bool, err error) {
+	now := time.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		item := e.Value.(*memoryItem)
+		s.list.MoveToFront(e)
+		if now.Before(item.expiry) {
+			return false, nil
+		}
+
+		item.expiry = now.Add(ttl)
+		return true, nil
+	}
+
+	// Evict the expired keys from the back, or the least recently used one if full.
+	for e := s.list.Back(); e != nil; e = s.list.Back() {
+		if item := e.Value.(*memoryItem); s.list.Len() < s.cap && now.Before(item.expiry) {
+			break
+		}
+		s.remove(e)
+	}
+
+	s.items[key] = s.list.PushFront(&memoryItem{key: key, expiry: now.Add(ttl)})
+	return true, nil
+}
+
+// Delete implements the interface Store.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+func (s *MemoryStore) remove(e *list.Element) {
+	delete(s.items, e.Value.(*memoryItem).key)
+	s.list.Remove(e)
+}
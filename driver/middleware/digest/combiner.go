@@ -0,0 +1,285 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digest
+
+import (
+	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/tools/email"
+)
+
+// Combiner is used to combine a set of messages, at least two,
+// into one message.
+type Combiner func(ms []driver.Message) (driver.Message, error)
+
+// DefaultCombiner returns the default combiner by the driver type.
+//
+//	"feishu.webhook": FeishuCombiner
+//	"email", "sendmail", "sendgrid", "mailgun", "ses": EmailCombiner
+//	others: TextCombiner
+func DefaultCombiner(dtype string) Combiner {
+	switch dtype {
+	case "feishu.webhook":
+		return FeishuCombiner
+	case "email", "sendmail", "sendgrid", "mailgun", "ses":
+		return EmailCombiner
+	default:
+		return TextCombiner
+	}
+}
+
+// combined returns a new message with the content based on the first message.
+func combined(first driver.Message, content any) driver.Message {
+	first.Content = content
+	first.Metadata = maps.Clone(first.Metadata)
+	return first
+}
+
+func more(title string, n int) string {
+	return fmt.Sprintf("%s (+%d)", title, n-1)
+}
+
+// TextCombiner combines the messages whose contents are strings
+// by joining them with the blank lines.
+func TextCombiner(ms []driver.Message) (driver.Message, error) {
+	var b strings.Builder
+	for i, m := range ms {
+		content, ok := m.Content.(string)
+		if !ok {
+			return driver.Message{}, fmt.Errorf("digest: expect the content is a string, but got %T", m.Content)
+		}
+
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(content)
+	}
+	return combined(ms[0], b.String()), nil
+}
+
+/// ----------------------------------------------------------------------- ///
+
+// FeishuCombiner combines the messages of the feishu webhook.
+//
+// If all the messages are the text, it is the same as TextCombiner.
+// Or, combine them into a rich text message, that's, the "post" MsgType,
+// whose title is that of the first message with the number of the rest,
+// and the messages are separated by the horizontal rules. For each message,
+// the title is rendered as a bold paragraph, and the text content is
+// rendered as a paragraph in every language.
+func FeishuCombiner(ms []driver.Message) (driver.Message, error) {
+	var langs []string
+	for _, m := range ms {
+		if msgtype, _ := m.Metadata["MsgType"].(string); msgtype == "post" {
+			post, ok := m.Content.(map[string]any)
+			if !ok {
+				return driver.Message{}, fmt.Errorf("digest: expect the post content is a map[string]any, but got %T", m.Content)
+			}
+			for lang := range post {
+				if !slices.Contains(langs, lang) {
+					langs = append(langs, lang)
+				}
+			}
+		}
+	}
+
+	if len(langs) == 0 {
+		return TextCombiner(ms)
+	}
+	slices.Sort(langs)
+
+	titles := make(map[string]string, len(langs))
+	contents := make(map[string][]any, len(langs))
+	for i, m := range ms {
+		if i > 0 {
+			for _, lang := range langs {
+				contents[lang] = append(contents[lang], []any{map[string]any{"tag": "hr"}})
+			}
+		}
+
+		if msgtype, _ := m.Metadata["MsgType"].(string); msgtype != "post" {
+			text, ok := m.Content.(string)
+			if !ok {
+				return driver.Message{}, fmt.Errorf("digest: expect the content is a string, but got %T", m.Content)
+			}
+
+			for _, lang := range langs {
+				contents[lang] = append(contents[lang], []any{map[string]any{"tag": "text", "text": text}})
+			}
+			continue
+		}
+
+		post := m.Content.(map[string]any)
+		for _, lang := range langs {
+			v, ok := post[lang]
+			if !ok { // Fall back to the first language of the message.
+				v = post[firstkey(post)]
+			}
+
+			title, paragraphs, err := decodePost(v)
+			if err != nil {
+				return driver.Message{}, err
+			}
+
+			if _, ok := titles[lang]; !ok {
+				titles[lang] = title
+			}
+			if title != "" {
+				contents[lang] = append(contents[lang], []any{map[string]any{
+					"tag": "text", "text": title, "style": []string{"bold"},
+				}})
+			}
+			contents[lang] = append(contents[lang], paragraphs...)
+		}
+	}
+
+	post := make(map[string]any, len(langs))
+	for _, lang := range langs {
+		post[lang] = map[string]any{"title": more(titles[lang], len(ms)), "content": contents[lang]}
+	}
+
+	m := combined(ms[0], post)
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]any, 1)
+	}
+	m.Metadata["MsgType"] = "post"
+	return m, nil
+}
+
+func firstkey(m map[string]any) (first string) {
+	for key := range m {
+		if first == "" || key < first {
+			first = key
+		}
+	}
+	return
+}
+
+func decodePost(v any) (title string, paragraphs []any, err error) {
+	post, ok := v.(map[string]any)
+	if !ok {
+		err = fmt.Errorf("digest: expect the post content of a language is a map[string]any, but got %T", v)
+		return
+	}
+
+	title, _ = post["title"].(string)
+	switch content := post["content"].(type) {
+	case nil:
+	case []any:
+		paragraphs = content
+	case [][]any:
+		paragraphs = make([]any, len(content))
+		for i, p := range content {
+			paragraphs[i] = p
+		}
+	case [][]map[string]any:
+		paragraphs = make([]any, len(content))
+		for i, p := range content {
+			paragraphs[i] = p
+		}
+	default:
+		err = fmt.Errorf("digest: unsupported post paragraphs %T", content)
+	}
+	return
+}
+
+/// ----------------------------------------------------------------------- ///
+
+// EmailCombiner combines the email messages into a html message,
+// whose subject is that of the first message with the number of the rest,
+// and the messages are separated by the horizontal rules. For each message,
+// the subject is rendered as a heading, and the text content is escaped
+// and preformatted.
+//
+// For a full html document, only the inner content of the body element
+// is kept, and the others, such as the doctype, the head and the style
+// sheets in it, are dropped, so the styles of the message are lost unless
+// they are inlined. A html fragment is kept as it is.
+//
+// The header fields, such as From, Cc and Headers, are those of the first
+// message, and the attachments of all the messages are merged.
+func EmailCombiner(ms []driver.Message) (driver.Message, error) {
+	var b strings.Builder
+	var first email.Message
+	for i, m := range ms {
+		mail, err := email.Decode(m.Content)
+		if err != nil {
+			return driver.Message{}, err
+		}
+
+		if i == 0 {
+			first = mail
+			first.Attachments = slices.Clone(mail.Attachments)
+		} else {
+			b.WriteString("<hr>\n")
+			first.Attachments = append(first.Attachments, mail.Attachments...)
+		}
+
+		if mail.Subject != "" {
+			fmt.Fprintf(&b, "<h3>%s</h3>\n", html.EscapeString(mail.Subject))
+		}
+
+		if mail.ContentType == email.ContentTypeText {
+			fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(mail.Content))
+		} else {
+			b.WriteString(htmlBody(mail.Content))
+			b.WriteByte('\n')
+		}
+	}
+
+	first.Subject = more(first.Subject, len(ms))
+	first.Content = b.String()
+	first.ContentType = email.ContentTypeHTML
+	first.Text = ""
+	return combined(ms[0], first), nil
+}
+
+// htmlBody returns the inner content of the body element of the html document.
+// If there is no body element, return the content as it is.
+func htmlBody(content string) string {
+	lower := asciiLower(content)
+	start := strings.Index(lower, "<body")
+	if start < 0 {
+		return content
+	}
+
+	end := strings.IndexByte(lower[start:], '>')
+	if end < 0 {
+		return content
+	}
+	start += end + 1
+
+	if end = strings.LastIndex(lower, "</body"); end < start {
+		end = len(content)
+	}
+	return strings.TrimSpace(content[start:end])
+}
+
+// asciiLower is the same as strings.ToLower, but only converts the ascii
+// letters, so that the result has the same byte offsets as s.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,249 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package digest provides a driver middleware to buffer the messages
+// to the same receiver and send them as one combined message.
+package digest
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+	"github.com/xgfone/go-msgnotice/driver/middleware/recovery"
+)
+
+// Config is used to configure the digest middleware.
+type Config struct {
+	// Window is the maximum duration to buffer the messages
+	// since the first one of a key.
+	//
+	// Default: 1m
+	Window time.Duration
+
+	// MaxCount is the maximum number of the buffered messages of a key.
+	// When reached, they are combined and sent at once.
+	//
+	// Default: 20
+	MaxCount int
+
+	// Key returns the key of the message, and the messages with the same key
+	// are combined together.
+	//
+	// Default: ByChannelReceiver
+	Key func(driver.Message) string
+
+	// Combiner is used to combine the buffered messages into one.
+	//
+	// Default: DefaultCombiner(driver.Type())
+	Combiner Combiner
+
+	// Bypass reports whether the message is sent directly without buffering,
+	// such as the critical messages.
+	//
+	// Default: nil
+	Bypass func(driver.Message) bool
+
+	// SendTimeout is the timeout to send the combined message
+	// when the window ends or the driver is stopped.
+	//
+	// Default: 10s
+	SendTimeout time.Duration
+
+	// OnError is called when failing to send the combined message,
+	// or panicking, when the window ends or the driver is stopped.
+	//
+	// Default: log the error by slog
+	OnError func(d driver.Driver, ms []driver.Message, err error)
+}
+
+// ByChannelReceiver returns the channel name and receiver as the key.
+func ByChannelReceiver(m driver.Message) string { return m.Name + "\x00" + m.Receiver }
+
+func logerror(d driver.Driver, ms []driver.Message, err error) {
+	slog.Error("fail to send the digest message",
+		slog.String("driver", d.Name()), slog.Int("count", len(ms)),
+		slog.String("receiver", ms[0].Receiver), slog.String("err", err.Error()))
+}
+
+// New returns a new digest middleware, which buffers the messages with
+// the same key and sends them as one combined message by the combiner
+// when the window ends or the count reaches MaxCount. If only one message
+// is buffered, it is sent as it is.
+//
+// Sending the message to buffer returns nil immediately, except the one
+// reaching MaxCount, which sends the combined message with its context
+// and returns the result. When the window ends, the combined message is
+// sent with a new context in the background, so the values of the original
+// contexts are not passed.
+//
+// When the driver is stopped, all the pending messages are flushed,
+// the in-progress flushes of the ended windows are waited for,
+// and the messages after that are sent directly.
+//
+// The panic when combining or sending the messages in the background
+// is recovered and reported to OnError as recovery.PanicError.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.MaxCount <= 0 {
+		config.MaxCount = 20
+	}
+	if config.Key == nil {
+		config.Key = ByChannelReceiver
+	}
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = 10 * time.Second
+	}
+	if config.OnError == nil {
+		config.OnError = logerror
+	}
+
+	return middleware.NewWithMatch("digest", priority, matcher, func(d driver.Driver) driver.Driver {
+		combiner := config.Combiner
+		if combiner == nil {
+			combiner = DefaultCombiner(d.Type())
+		}
+
+		return &digestDriver{
+			Driver:   d,
+			config:   config,
+			combiner: combiner,
+			buffers:  make(map[string]*buffer, 8),
+		}
+	})
+}
+
+type buffer struct {
+	msgs  []driver.Message
+	timer *time.Timer
+}
+
+type digestDriver struct {
+	driver.Driver
+	config   Config
+	combiner Combiner
+
+	lock    sync.Mutex
+	buffers map[string]*buffer
+	stopped bool
+	timers  sync.WaitGroup
+}
+
+func (d *digestDriver) Unwrap() driver.Driver { return d.Driver }
+
+func (d *digestDriver) Send(c context.Context, m driver.Message) error {
+	if d.config.Bypass != nil && d.config.Bypass(m) {
+		return d.Driver.Send(c, m)
+	}
+
+	key := d.config.Key(m)
+
+	d.lock.Lock()
+	if d.stopped {
+		d.lock.Unlock()
+		return d.Driver.Send(c, m)
+	}
+
+	b := d.buffers[key]
+	if b == nil {
+		b = &buffer{msgs: make([]driver.Message, 0, 4)}
+		d.timers.Add(1)
+		b.timer = time.AfterFunc(d.config.Window, func() { d.expire(key, b) })
+		d.buffers[key] = b
+	}
+
+	b.msgs = append(b.msgs, m)
+	if len(b.msgs) < d.config.MaxCount {
+		d.lock.Unlock()
+		return nil
+	}
+
+	delete(d.buffers, key)
+	d.lock.Unlock()
+
+	d.stopTimer(b)
+	return d.send(c, b.msgs)
+}
+
+func (d *digestDriver) Stop() {
+	d.lock.Lock()
+	buffers := d.buffers
+	d.buffers = nil
+	d.stopped = true
+	d.lock.Unlock()
+
+	for _, b := range buffers {
+		d.stopTimer(b)
+		d.flush(b.msgs)
+	}
+
+	d.timers.Wait()
+	d.Driver.Stop()
+}
+
+// stopTimer stops the timer of the buffer, and marks it as done
+// if it has not fired.
+func (d *digestDriver) stopTimer(b *buffer) {
+	if b.timer.Stop() {
+		d.timers.Done()
+	}
+}
+
+func (d *digestDriver) expire(key string, b *buffer) {
+	defer d.timers.Done()
+
+	d.lock.Lock()
+	if d.buffers[key] != b { // Has been flushed.
+		d.lock.Unlock()
+		return
+	}
+	delete(d.buffers, key)
+	d.lock.Unlock()
+
+	d.flush(b.msgs)
+}
+
+func (d *digestDriver) flush(ms []driver.Message) {
+	c, cancel := context.WithTimeout(context.Background(), d.config.SendTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err := recovery.PanicError{Channel: ms[0].Name, Driver: d.Driver.Name(), Value: r, Stack: debug.Stack()}
+			d.config.OnError(d.Driver, ms, err)
+		}
+	}()
+
+	if err := d.send(c, ms); err != nil {
+		d.config.OnError(d.Driver, ms, err)
+	}
+}
+
+func (d *digestDriver) send(c context.Context, ms []driver.Message) error {
+	if len(ms) == 1 {
+		return d.Driver.Send(c, ms[0])
+	}
+
+	m, err := d.combiner(ms)
+	if err != nil {
+		return err
+	}
+	return d.Driver.Send(c, m)
+}
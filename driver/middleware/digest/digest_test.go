@@ -0,0 +1,196 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware/recovery"
+	"github.com/xgfone/go-msgnotice/tools/email"
+)
+
+// recorder records the contents of the sent messages.
+type recorder struct {
+	lock sync.Mutex
+	msgs []any
+}
+
+func (r *recorder) send(_ context.Context, m driver.Message) error {
+	r.lock.Lock()
+	r.msgs = append(r.msgs, m.Content)
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *recorder) contents() []any {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]any(nil), r.msgs...)
+}
+
+func TestDigest(t *testing.T) {
+	d := new(recorder)
+	dd := New(0, Config{Window: 50 * time.Millisecond, MaxCount: 3}, nil).Driver(driver.New("test", "test", d.send, nil))
+
+	send := func(receiver, content string) {
+		if err := dd.Send(context.Background(), driver.Message{Receiver: receiver, Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("a", "1")
+	send("b", "2")
+	send("a", "3")
+	send("a", "4") // Reach MaxCount
+	if expect := []any{"1\n\n3\n\n4"}; !reflect.DeepEqual(d.contents(), expect) {
+		t.Errorf("expect %v, but got %v", expect, d.contents())
+	}
+
+	time.Sleep(100 * time.Millisecond) // Window ends
+	if expect := []any{"1\n\n3\n\n4", "2"}; !reflect.DeepEqual(d.contents(), expect) {
+		t.Errorf("expect %v, but got %v", expect, d.contents())
+	}
+
+	send("a", "5")
+	send("a", "6")
+	dd.Stop()
+	send("a", "7")
+	if expect := []any{"1\n\n3\n\n4", "2", "5\n\n6", "7"}; !reflect.DeepEqual(d.contents(), expect) {
+		t.Errorf("expect %v, but got %v", expect, d.contents())
+	}
+}
+
+func TestFeishuCombiner(t *testing.T) {
+	post := map[string]any{"zh_cn": map[string]any{
+		"title":   "title",
+		"content": []any{[]any{map[string]any{"tag": "text", "text": "post"}}},
+	}}
+
+	m, err := FeishuCombiner([]driver.Message{
+		{Content: post, Metadata: map[string]any{"MsgType": "post"}},
+		{Content: "text"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]any{"zh_cn": map[string]any{
+		"title": "title (+1)",
+		"content": []any{
+			[]any{map[string]any{"tag": "text", "text": "title", "style": []string{"bold"}}},
+			[]any{map[string]any{"tag": "text", "text": "post"}},
+			[]any{map[string]any{"tag": "hr"}},
+			[]any{map[string]any{"tag": "text", "text": "text"}},
+		},
+	}}
+	if !reflect.DeepEqual(m.Content, expect) {
+		t.Errorf("expect %v, but got %v", expect, m.Content)
+	}
+	if _, ok := post["zh_cn"].(map[string]any)["style"]; ok {
+		t.Errorf("the original content is modified")
+	}
+}
+
+func TestDigestStop(t *testing.T) {
+	var stopped bool
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	d := driver.New("test", "test", func(context.Context, driver.Message) error {
+		close(sending)
+		<-release
+		if stopped {
+			t.Errorf("the driver is stopped before the flush finishes")
+		}
+		return nil
+	}, func() { stopped = true })
+
+	dd := New(0, Config{Window: 10 * time.Millisecond}, nil).Driver(d)
+	if err := dd.Send(context.Background(), driver.Message{Receiver: "a", Content: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	<-sending // The window ends and the flush is in progress.
+	done := make(chan struct{})
+	go func() { dd.Stop(); close(done) }()
+
+	select {
+	case <-done:
+		t.Fatal("Stop returns before the flush finishes")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+	if !stopped {
+		t.Errorf("the driver is not stopped")
+	}
+}
+
+func TestDigestPanic(t *testing.T) {
+	errs := make(chan error, 1)
+	config := Config{
+		Window:   10 * time.Millisecond,
+		Combiner: func([]driver.Message) (driver.Message, error) { panic("combine") },
+		OnError:  func(_ driver.Driver, _ []driver.Message, err error) { errs <- err },
+	}
+
+	dd := New(0, config, nil).Driver(driver.New("test", "test", new(recorder).send, nil))
+	_ = dd.Send(context.Background(), driver.Message{Name: "channel", Receiver: "a", Content: "1"})
+	_ = dd.Send(context.Background(), driver.Message{Name: "channel", Receiver: "a", Content: "2"})
+
+	select {
+	case err := <-errs:
+		var perr recovery.PanicError
+		if !errors.As(err, &perr) {
+			t.Errorf("expect a PanicError, but got %v", err)
+		} else if perr.Value != "combine" || perr.Channel != "channel" || perr.Driver != "test" {
+			t.Errorf("unexpected panic error: %+v", perr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the panic is not reported")
+	}
+}
+
+func TestEmailCombiner(t *testing.T) {
+	m, err := EmailCombiner([]driver.Message{
+		{Content: email.Message{Subject: "s1", Content: "<!DOCTYPE html>\n<HTML><Head><title>t</title></Head>\n<Body class=\"x\">\n<p>1</p>\n</BODY></HTML>"}},
+		{Content: email.Message{Subject: "s2", Content: "<p>2</p>"}},
+		{Content: email.Message{Subject: "s3", Content: "a<b", ContentType: email.ContentTypeText}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := m.Content.(email.Message)
+	if mail.Subject != "s1 (+2)" {
+		t.Errorf("expect subject '%s', but got '%s'", "s1 (+2)", mail.Subject)
+	}
+
+	expect := strings.Join([]string{
+		"<h3>s1</h3>", "<p>1</p>", "<hr>",
+		"<h3>s2</h3>", "<p>2</p>", "<hr>",
+		"<h3>s3</h3>", "<pre>a&lt;b</pre>", "",
+	}, "\n")
+	if mail.Content != expect {
+		t.Errorf("expect content '%s', but got '%s'", expect, mail.Content)
+	}
+}
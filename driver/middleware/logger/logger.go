@@ -25,36 +25,130 @@ import (
 	"github.com/xgfone/go-msgnotice/driver/middleware"
 )
 
-// New returns a new logger middleware to log the sent message.
+// Config is used to configure the logger middleware.
+type Config struct {
+	// Default: slog.Default()
+	Logger *slog.Logger
+
+	// SuccessLevel and FailureLevel are the levels to log the message
+	// when succeeding and failing to send it, which may be *slog.LevelVar
+	// to change them dynamically.
+	//
+	// Default: slog.LevelInfo and slog.LevelError
+	SuccessLevel slog.Leveler
+	FailureLevel slog.Leveler
+
+	// Receiver is used to mask the receiver, such as MaskReceiver.
+	//
+	// Default: nil, that's, log it as it is.
+	Receiver func(receiver string) string
+
+	// Content is the policy to log the message content.
+	//
+	// For ContentKeep, the structured content, such as the email message
+	// and the feishu post, is also redacted by RedactKeys.
+	//
+	// Default: ContentKeep
+	Content ContentPolicy
+
+	// MaxContentLength is the maximum number of the runes of the content
+	// to be logged, and the longer is truncated. If the content is not
+	// a string, it is encoded by JSON first. 0 means no limit.
+	MaxContentLength int
+
+	// RedactKeys is the patterns of the metadata keys, whose values are
+	// replaced with "[REDACTED]", such as DefaultRedactKeys.
+	// The pattern is matched case-insensitively by path.Match,
+	// and the nested maps are redacted recursively.
+	RedactKeys []string
+
+	// ContextAttrs returns the extra attributes from the context,
+	// such as the request id.
+	ContextAttrs func(context.Context) []slog.Attr
+}
+
+// New returns a new logger middleware to log the sent message by SafeConfig,
+// which masks the receiver, redacts the metadata and logs the hash of the
+// content instead of itself, so that the secrets, such as the OTP in the
+// content and the key in the webhook url, are not leaked.
+//
+// Notice: the older versions log the receiver, the content and the metadata
+// as they are. To keep it, use NewWithConfig(priority, Config{}, matcher).
 func New(priority int, matcher driver.Matcher) middleware.Middleware {
+	return NewWithConfig(priority, SafeConfig(), matcher)
+}
+
+// SafeConfig returns the config that does not log the sensitive information,
+// which is used by New, that's,
+//
+//	Config{Receiver: MaskReceiver, Content: ContentHash, RedactKeys: DefaultRedactKeys}
+func SafeConfig() Config {
+	return Config{Receiver: MaskReceiver, Content: ContentHash, RedactKeys: DefaultRedactKeys}
+}
+
+// NewWithConfig returns a new logger middleware with the config
+// to log the sent message.
+func NewWithConfig(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.SuccessLevel == nil {
+		config.SuccessLevel = slog.LevelInfo
+	}
+	if config.FailureLevel == nil {
+		config.FailureLevel = slog.LevelError
+	}
+
+	redactor := newRedactor(config.RedactKeys)
 	return middleware.NewWithMatch("logger", priority, matcher, func(d driver.Driver) driver.Driver {
 		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
 			start := time.Now()
 			err = d.Send(c, m)
+			cost := time.Since(start)
+
+			logger := config.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+
+			level, msg := config.SuccessLevel.Level(), "successfully send message notice"
+			if err != nil {
+				level, msg = config.FailureLevel.Level(), "fail to send message notice"
+			}
+			if !logger.Enabled(c, level) {
+				return
+			}
+
+			receiver := m.Receiver
+			if config.Receiver != nil {
+				receiver = config.Receiver(receiver)
+			}
 
-			_args := getargs()
-			defer putargs(_args)
-			args := _args.Args[:0]
+			_attrs := getattrs()
+			defer putattrs(_attrs)
+			attrs := _attrs.Attrs[:0]
 
-			args = append(args, slog.String("channel", m.Name), slog.String("driver", m.Type))
-			args = append(args, slog.String("receiver", m.Receiver), slog.Any("content", m.Content))
-			args = append(args, slog.Any("metadata", m.Metadata), slog.Duration("cost", time.Since(start)))
+			attrs = append(attrs, slog.String("channel", m.Name), slog.String("driver", m.Type))
+			attrs = append(attrs, slog.String("receiver", receiver))
+			if content, ok := config.content(m.Content, redactor); ok {
+				attrs = append(attrs, slog.Any("content", content))
+			}
+			attrs = append(attrs, slog.Any("metadata", redactor.redact(m.Metadata)))
+			attrs = append(attrs, slog.Duration("cost", cost))
+			if config.ContextAttrs != nil {
+				attrs = append(attrs, config.ContextAttrs(c)...)
+			}
 
 			if err != nil {
-				args = append(args, slog.Any("err", err))
-				slog.Error("fail to send message notice", args...)
-			} else {
-				slog.Info("successfully send message notice", args...)
+				attrs = append(attrs, slog.Any("err", err))
 			}
 
+			logger.LogAttrs(c, level, msg, attrs...)
 			return
 		})
 	})
 }
 
-type _Args struct{ Args []any }
+type _Attrs struct{ Attrs []slog.Attr }
 
-func putargs(a *_Args) { argspool.Put(a) }
-func getargs() *_Args  { return argspool.Get().(*_Args) }
+func putattrs(a *_Attrs) { attrspool.Put(a) }
+func getattrs() *_Attrs  { return attrspool.Get().(*_Attrs) }
 
-var argspool = sync.Pool{New: func() any { return &_Args{Args: make([]any, 0, 8)} }}
+var attrspool = sync.Pool{New: func() any { return &_Attrs{Attrs: make([]slog.Attr, 0, 8)} }}
@@ -0,0 +1,162 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+type ctxkey struct{}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	failure := new(slog.LevelVar)
+	failure.Set(slog.LevelWarn)
+	config := Config{
+		Logger:       slog.New(slog.NewJSONHandler(&buf, nil)),
+		FailureLevel: failure,
+		Receiver:     MaskReceiver,
+		Content:      ContentHash,
+		RedactKeys:   DefaultRedactKeys,
+		ContextAttrs: func(c context.Context) []slog.Attr {
+			return []slog.Attr{slog.Any("reqid", c.Value(ctxkey{}))}
+		},
+	}
+
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { return errors.New("error") }, nil)
+	d = NewWithConfig(0, config, nil).Driver(d)
+
+	metadata := map[string]any{"OTP": "123456", "Headers": map[string]string{"X-Api-Token": "token", "X-Id": "id"}}
+	c := context.WithValue(context.Background(), ctxkey{}, "abc")
+	_ = d.Send(c, driver.NewMessage("channel", "test", `"Doe, John" <a@example.com>,+8613800001234`, "content", metadata))
+
+	var log map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	expects := map[string]any{
+		"level":    "WARN",
+		"receiver": "a***@example.com,+*********1234",
+		"content":  "sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73",
+		"metadata": map[string]any{"OTP": Redacted, "Headers": map[string]any{"X-Api-Token": Redacted, "X-Id": "id"}},
+		"reqid":    "abc",
+		"err":      "error",
+	}
+	for key, expect := range expects {
+		if value := log[key]; !reflect.DeepEqual(value, expect) {
+			t.Errorf("%s: expect %v, but got %v", key, expect, value)
+		}
+	}
+
+	if metadata["OTP"] != "123456" {
+		t.Errorf("the original metadata is modified")
+	}
+
+	buf.Reset()
+	failure.Set(slog.LevelDebug)
+	_ = d.Send(c, driver.Message{})
+	if buf.Len() > 0 {
+		t.Errorf("unexpected the disabled log: %s", buf.String())
+	}
+}
+
+func TestContentTruncation(t *testing.T) {
+	config := Config{MaxContentLength: 2}
+	if content, _ := config.content("你好世界", nil); content != "你好...(truncated, 12 bytes)" {
+		t.Errorf("unexpected content: %v", content)
+	}
+	if content, _ := config.content(map[string]any{"a": 1}, nil); content != `{"...(truncated, 7 bytes)` {
+		t.Errorf("unexpected content: %v", content)
+	}
+}
+
+func TestLoggerDefault(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { return nil }, nil)
+	d = New(0, nil).Driver(d)
+	_ = d.Send(context.Background(), driver.NewMessage("channel", "test", "a", "123456", map[string]any{"Token": "token"}))
+
+	var log map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	expects := map[string]any{
+		"receiver": "***",
+		"content":  "sha256:8d969eef6ecad3c29a3a629280e686cf0c3f5d5a86aff3ca12020c923adc6c92",
+		"metadata": map[string]any{"Token": Redacted},
+	}
+	for key, expect := range expects {
+		if value := log[key]; !reflect.DeepEqual(value, expect) {
+			t.Errorf("%s: expect %v, but got %v", key, expect, value)
+		}
+	}
+}
+
+func TestContentRedaction(t *testing.T) {
+	type Message struct {
+		Subject string
+		Headers map[string]string
+	}
+
+	r := newRedactor(DefaultRedactKeys)
+	content, _ := Config{}.content(Message{Subject: "subject", Headers: map[string]string{"Authorization": "token"}}, r)
+	expect := map[string]any{"Subject": "subject", "Headers": map[string]any{"Authorization": Redacted}}
+	if !reflect.DeepEqual(content, expect) {
+		t.Errorf("expect %v, but got %v", expect, content)
+	}
+
+	post := map[string]any{"zh_cn": map[string]any{"content": []any{[]any{map[string]any{"tag": "text", "otp": "123"}}}}}
+	content, _ = Config{}.content(post, r)
+	expect = map[string]any{"zh_cn": map[string]any{"content": []any{[]any{map[string]any{"tag": "text", "otp": Redacted}}}}}
+	if !reflect.DeepEqual(content, expect) {
+		t.Errorf("expect %v, but got %v", expect, content)
+	}
+
+	message := Message{Subject: "subject"}
+	if content, _ = (Config{}).content(message, r); !reflect.DeepEqual(content, message) {
+		t.Errorf("expect the original content, but got %v", content)
+	}
+}
+
+func TestMaskReceiver(t *testing.T) {
+	for receiver, expect := range map[string]string{
+		"":                         "",
+		"user@example.com":         "u***@example.com",
+		`"Doe, John" <j@a.com>, b`: "j***@a.com,***",
+		"+86 138-0000-1234":        "+*********1234",
+		"13800001234":              "*******1234",
+		"https://host/path?k=v":    "https://host/***",
+		"abcdefghijk":              "ab***jk",
+		"张三@example.com":           "张***@example.com",
+		"一二三四五六七八九":                "一二***八九",
+	} {
+		if result := MaskReceiver(receiver); result != expect {
+			t.Errorf("%s: expect '%s', but got '%s'", receiver, expect, result)
+		}
+	}
+}
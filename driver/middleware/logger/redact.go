@@ -0,0 +1,298 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/mail"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+// ContentPolicy is the policy to log the message content.
+type ContentPolicy int
+
+const (
+	// ContentKeep logs the content as it is.
+	ContentKeep ContentPolicy = iota
+
+	// ContentDrop does not log the content.
+	ContentDrop
+
+	// ContentHash logs the SHA256 hash of the content, such as "sha256:HEX",
+	// which is used to correlate the same contents without exposing them.
+	ContentHash
+)
+
+// Redacted is the placeholder of the redacted metadata values.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys is the default patterns of the sensitive metadata keys.
+var DefaultRedactKeys = []string{
+	"*password*", "*passwd*", "*secret*", "*token*",
+	"*apikey*", "*api_key*", "*otp*", "*captcha*", "*verif*code*", "authorization",
+}
+
+// contentString returns the string representation of the content.
+func contentString(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	}
+
+	if data, err := json.Marshal(content); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(content)
+}
+
+func (c Config) content(content any, r redactor) (any, bool) {
+	switch c.Content {
+	case ContentDrop:
+		return nil, false
+
+	case ContentHash:
+		sum := sha256.Sum256([]byte(contentString(content)))
+		return "sha256:" + hex.EncodeToString(sum[:]), true
+	}
+
+	content = r.redactcontent(content)
+	if c.MaxContentLength > 0 {
+		s := contentString(content)
+		var n int
+		for i := range s {
+			if n == c.MaxContentLength {
+				return fmt.Sprintf("%s...(truncated, %d bytes)", s[:i], len(s)), true
+			}
+			n++
+		}
+		return s, true
+	}
+
+	return content, true
+}
+
+/// ----------------------------------------------------------------------- ///
+
+type redactor []string
+
+func newRedactor(patterns []string) redactor {
+	r := make(redactor, len(patterns))
+	for i, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Errorf("logger: invalid redact key pattern '%s': %w", pattern, err))
+		}
+		r[i] = strings.ToLower(pattern)
+	}
+	return r
+}
+
+func (r redactor) match(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns a copy of the metadata with the redacted values
+// if any key is matched. Or, return the original.
+func (r redactor) redact(metadata map[string]any) map[string]any {
+	if len(r) == 0 || len(metadata) == 0 {
+		return metadata
+	}
+
+	if redacted, ok := r.redactmap(metadata); ok {
+		return redacted
+	}
+	return metadata
+}
+
+func (r redactor) redactmap(m map[string]any) (redacted map[string]any, ok bool) {
+	for key, value := range m {
+		if value, ok = r.redactvalue(key, value); ok {
+			if redacted == nil {
+				redacted = maps.Clone(m)
+			}
+			redacted[key] = value
+		}
+	}
+	return redacted, redacted != nil
+}
+
+func (r redactor) redactstrings(m map[string]string) (redacted map[string]string, ok bool) {
+	for key := range m {
+		if r.match(key) {
+			if redacted == nil {
+				redacted = maps.Clone(m)
+			}
+			redacted[key] = Redacted
+		}
+	}
+	return redacted, redacted != nil
+}
+
+func (r redactor) redactslice(s []any) (redacted []any, ok bool) {
+	for i, value := range s {
+		if value, ok = r.redactany(value); ok {
+			if redacted == nil {
+				redacted = slices.Clone(s)
+			}
+			redacted[i] = value
+		}
+	}
+	return redacted, redacted != nil
+}
+
+func (r redactor) redactvalue(key string, value any) (any, bool) {
+	if r.match(key) {
+		return Redacted, true
+	}
+	return r.redactany(value)
+}
+
+func (r redactor) redactany(value any) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return r.redactmap(v)
+	case map[string]string:
+		return r.redactstrings(v)
+	case []any:
+		return r.redactslice(v)
+	default:
+		return value, false
+	}
+}
+
+// redactcontent returns the structured content redacted by the keys,
+// which is normalized by JSON first, such as the email message.
+// Or, return the original if it is a string or no key is matched.
+func (r redactor) redactcontent(content any) any {
+	if len(r) == 0 {
+		return content
+	}
+
+	var data []byte
+	switch v := content.(type) {
+	case nil, string, []byte:
+		return content
+	case json.RawMessage:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(content); err != nil {
+			return content
+		}
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return content
+	}
+
+	if redacted, ok := r.redactany(value); ok {
+		return redacted
+	}
+	return content
+}
+
+/// ----------------------------------------------------------------------- ///
+
+// MaskReceiver masks the comma-separated receivers split by
+// driver.SplitReceivers, such as the email addresses, phone numbers
+// and webhook urls, which is used by Config.Receiver. The display names
+// of the email addresses are dropped.
+//
+//	"user@example.com"  => "u***@example.com"
+//	"+8613800001234"    => "+*********1234"
+//	"https://host/path" => "https://host/***"
+//	others              => "ab***yz", or "***" if not longer than 8
+func MaskReceiver(receiver string) string {
+	if receiver == "" {
+		return ""
+	}
+
+	receivers := driver.SplitReceivers(receiver)
+	for i, r := range receivers {
+		receivers[i] = maskOne(r)
+	}
+	return strings.Join(receivers, ",")
+}
+
+func maskOne(r string) string {
+	if u, err := url.Parse(r); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host + "/***"
+	}
+
+	if addr, err := mail.ParseAddress(r); err == nil {
+		i := strings.LastIndexByte(addr.Address, '@')
+		_, size := utf8.DecodeRuneInString(addr.Address)
+		return addr.Address[:size] + "***" + addr.Address[i:]
+	}
+
+	if isPhone(r) {
+		var prefix string
+		if r[0] == '+' {
+			prefix = "+"
+		}
+
+		digits := onlyDigits(r)
+		return prefix + strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	}
+
+	runes := []rune(r)
+	if len(runes) <= 8 {
+		return "***"
+	}
+	return string(runes[:2]) + "***" + string(runes[len(runes)-2:])
+}
+
+func isPhone(s string) bool {
+	var digits int
+	for i, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '+' && i == 0, c == '-', c == ' ', c == '(', c == ')':
+		default:
+			return false
+		}
+	}
+	return digits >= 7
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,397 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics provides a driver middleware to collect the metrics
+// of sending the messages, which are exposed in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+	"github.com/xgfone/go-msgnotice/driver/middleware/breaker"
+	"github.com/xgfone/go-msgnotice/driver/middleware/bulkhead"
+	"github.com/xgfone/go-msgnotice/driver/middleware/ratelimit"
+	"github.com/xgfone/go-msgnotice/driver/middleware/retry"
+)
+
+// DefaultBuckets is the default buckets of the latency histogram in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultMetrics is the default metrics used by New if nil.
+var DefaultMetrics = NewMetrics(Config{})
+
+// ErrorClass is the default classifier of the error, which returns
+//
+//	"ok" if err is nil.
+//	"timeout" if err is context.DeadlineExceeded.
+//	"canceled" if err is context.Canceled.
+//	"ratelimited" if err is ratelimit.Error.
+//	"breaker_open" if err is breaker.Error.
+//	"bulkhead_rejected" if err is bulkhead.Error.
+//	"transient" if err is transient by retry.IsTransient.
+//	"permanent" for others.
+func ErrorClass(err error) string {
+	var (
+		rerr ratelimit.Error
+		berr breaker.Error
+		herr bulkhead.Error
+	)
+
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &rerr):
+		return "ratelimited"
+	case errors.As(err, &berr):
+		return "breaker_open"
+	case errors.As(err, &herr):
+		return "bulkhead_rejected"
+	case retry.IsTransient(err):
+		return "transient"
+	default:
+		return "permanent"
+	}
+}
+
+// Config is used to configure the metrics.
+type Config struct {
+	// Namespace is the prefix of the metric names.
+	//
+	// Default: "msgnotice"
+	Namespace string
+
+	// Buckets is the upper bounds of the buckets of the latency histogram in seconds.
+	//
+	// Default: DefaultBuckets
+	Buckets []float64
+
+	// Classify returns the class of the error, which is used as the label "class".
+	// It should return a small set of the values.
+	//
+	// Default: ErrorClass
+	Classify func(error) string
+}
+
+// Metrics collects the metrics of sending the messages as follow:
+//
+//	{namespace}_messages_sent_total{channel, type}: counter of the messages sent successfully.
+//	{namespace}_messages_failed_total{channel, type, class}: counter of the messages failed to be sent.
+//	{namespace}_send_duration_seconds{channel, type, class}: histogram of the latency to send the messages.
+//	{namespace}_messages_inflight{channel}: gauge of the messages being sent.
+//
+// where channel is the name of the message, type is the type of the driver,
+// and class is the class of the error, which is "ok" for the successes.
+//
+// It implements the interface http.Handler to expose the metrics
+// in the Prometheus text format.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+	classify  func(error) string
+
+	lock      sync.Mutex
+	sent      map[string]*counter
+	failed    map[string]*counter
+	durations map[string]*histogram
+	inflight  map[string]*counter
+}
+
+type counter struct {
+	labels []string
+	value  float64
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // The count of each bucket, not cumulative.
+	count  uint64
+	sum    float64
+}
+
+// NewMetrics returns a new metrics.
+func NewMetrics(config Config) *Metrics {
+	if config.Namespace == "" {
+		config.Namespace = "msgnotice"
+	}
+	if len(config.Buckets) == 0 {
+		config.Buckets = DefaultBuckets
+	}
+	if config.Classify == nil {
+		config.Classify = ErrorClass
+	}
+
+	buckets := slices.Clone(config.Buckets)
+	slices.Sort(buckets)
+	buckets = slices.Compact(buckets)
+	if math.IsInf(buckets[len(buckets)-1], +1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+
+	return &Metrics{
+		namespace: config.Namespace,
+		buckets:   buckets,
+		classify:  config.Classify,
+
+		sent:      make(map[string]*counter, 8),
+		failed:    make(map[string]*counter, 8),
+		durations: make(map[string]*histogram, 8),
+		inflight:  make(map[string]*counter, 8),
+	}
+}
+
+// New returns a new metrics middleware to collect the metrics into m.
+//
+// If m is nil, use DefaultMetrics instead.
+func New(priority int, m *Metrics, matcher driver.Matcher) middleware.Middleware {
+	if m == nil {
+		m = DefaultMetrics
+	}
+
+	return middleware.NewWithMatch("metrics", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, msg driver.Message, d driver.Driver) (err error) {
+			m.addInflight(msg.Name, 1)
+			start := time.Now()
+			defer func() {
+				m.addInflight(msg.Name, -1)
+				m.observe(msg.Name, d.Type(), m.classify(err), err == nil, time.Since(start))
+			}()
+
+			return d.Send(c, msg)
+		})
+	})
+}
+
+func (m *Metrics) addInflight(channel string, delta float64) {
+	m.lock.Lock()
+	getCounter(m.inflight, channel).value += delta
+	m.lock.Unlock()
+}
+
+func (m *Metrics) observe(channel, dtype, class string, ok bool, duration time.Duration) {
+	seconds := duration.Seconds()
+	index := sort.SearchFloat64s(m.buckets, seconds) // The bucket with le >= seconds.
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if ok {
+		getCounter(m.sent, channel, dtype).value++
+	} else {
+		getCounter(m.failed, channel, dtype, class).value++
+	}
+
+	key := joinLabels(channel, dtype, class)
+	h := m.durations[key]
+	if h == nil {
+		h = &histogram{labels: []string{channel, dtype, class}, counts: make([]uint64, len(m.buckets)+1)}
+		m.durations[key] = h
+	}
+	h.counts[index]++
+	h.count++
+	h.sum += seconds
+}
+
+func getCounter(counters map[string]*counter, labels ...string) *counter {
+	key := joinLabels(labels...)
+	c := counters[key]
+	if c == nil {
+		c = &counter{labels: labels}
+		counters[key] = c
+	}
+	return c
+}
+
+func joinLabels(labels ...string) string { return strings.Join(labels, "\xff") }
+
+// ServeHTTP implements the interface http.Handler to expose the metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format into w.
+func (m *Metrics) WriteTo(w io.Writer) (n int64, err error) {
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	// Copy the metrics to write them without the lock,
+	// so that a slow writer does not block sending the messages.
+	m.lock.Lock()
+	sent := cloneCounters(m.sent)
+	failed := cloneCounters(m.failed)
+	durations := cloneHistograms(m.durations)
+	inflight := cloneCounters(m.inflight)
+	m.lock.Unlock()
+
+	m.writeCounters(bw, "messages_sent_total", "counter",
+		"The total number of the messages sent successfully.",
+		sent, "channel", "type")
+	m.writeCounters(bw, "messages_failed_total", "counter",
+		"The total number of the messages failed to be sent.",
+		failed, "channel", "type", "class")
+	m.writeHistograms(bw, "send_duration_seconds",
+		"The latency in seconds to send the messages.",
+		durations, "channel", "type", "class")
+	m.writeCounters(bw, "messages_inflight", "gauge",
+		"The number of the messages being sent.",
+		inflight, "channel")
+
+	err = bw.Flush()
+	return cw.n, err
+}
+
+func cloneCounters(counters map[string]*counter) map[string]counter {
+	clone := make(map[string]counter, len(counters))
+	for key, c := range counters {
+		clone[key] = *c
+	}
+	return clone
+}
+
+func cloneHistograms(histograms map[string]*histogram) map[string]histogram {
+	clone := make(map[string]histogram, len(histograms))
+	for key, h := range histograms {
+		h := *h
+		h.counts = slices.Clone(h.counts)
+		clone[key] = h
+	}
+	return clone
+}
+
+func (m *Metrics) writeHeader(w *bufio.Writer, name, mtype, help string) {
+	w.WriteString("# HELP ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(help)
+	w.WriteString("\n# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(mtype)
+	w.WriteByte('\n')
+}
+
+func (m *Metrics) writeCounters(w *bufio.Writer, name, mtype, help string,
+	counters map[string]counter, labels ...string) {
+	if len(counters) == 0 {
+		return
+	}
+
+	name = m.namespace + "_" + name
+	m.writeHeader(w, name, mtype, help)
+	for _, key := range sortedKeys(counters) {
+		c := counters[key]
+		writeSample(w, name, labels, c.labels, "", "", c.value)
+	}
+}
+
+func (m *Metrics) writeHistograms(w *bufio.Writer, name, help string,
+	histograms map[string]histogram, labels ...string) {
+	if len(histograms) == 0 {
+		return
+	}
+
+	name = m.namespace + "_" + name
+	m.writeHeader(w, name, "histogram", help)
+	for _, key := range sortedKeys(histograms) {
+		h := histograms[key]
+
+		var cumulative uint64
+		for i, upper := range m.buckets {
+			cumulative += h.counts[i]
+			writeSample(w, name+"_bucket", labels, h.labels, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, name+"_bucket", labels, h.labels, "le", "+Inf", float64(h.count))
+		writeSample(w, name+"_sum", labels, h.labels, "", "", h.sum)
+		writeSample(w, name+"_count", labels, h.labels, "", "", float64(h.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, names, values []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	w.WriteByte('{')
+	for i := range names {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		writeLabel(w, names[i], values[i])
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			w.WriteByte(',')
+		}
+		writeLabel(w, extraName, extraValue)
+	}
+	w.WriteString("} ")
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	labelEscaper.WriteString(w, value)
+	w.WriteByte('"')
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, +1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.n += int64(n)
+	return
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware/breaker"
+	"github.com/xgfone/go-msgnotice/driver/middleware/bulkhead"
+	"github.com/xgfone/go-msgnotice/driver/middleware/ratelimit"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(Config{Namespace: "test", Buckets: []float64{1, 0.5}})
+	mw := New(0, m, nil)
+
+	var derr error
+	d := mw.Driver(driver.New("test", "test", func(context.Context, driver.Message) error { return derr }, nil))
+
+	msg := driver.Message{Name: `a"b`}
+	for _, derr = range []error{nil, nil, context.DeadlineExceeded, errors.New("error")} {
+		_ = d.Send(context.Background(), msg)
+	}
+
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	for _, line := range []string{
+		"# TYPE test_messages_sent_total counter",
+		`test_messages_sent_total{channel="a\"b",type="test"} 2`,
+		`test_messages_failed_total{channel="a\"b",type="test",class="permanent"} 1`,
+		`test_messages_failed_total{channel="a\"b",type="test",class="timeout"} 1`,
+		"# TYPE test_send_duration_seconds histogram",
+		`test_send_duration_seconds_bucket{channel="a\"b",type="test",class="ok",le="0.5"} 2`,
+		`test_send_duration_seconds_bucket{channel="a\"b",type="test",class="ok",le="1"} 2`,
+		`test_send_duration_seconds_bucket{channel="a\"b",type="test",class="ok",le="+Inf"} 2`,
+		`test_send_duration_seconds_count{channel="a\"b",type="test",class="ok"} 2`,
+		"# TYPE test_messages_inflight gauge",
+		`test_messages_inflight{channel="a\"b"} 0`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("missing the line: %s", line)
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	for err, expect := range map[error]string{
+		nil:                   "ok",
+		context.Canceled:      "canceled",
+		ratelimit.Error{}:     "ratelimited",
+		breaker.Error{}:       "breaker_open",
+		bulkhead.Error{}:      "bulkhead_rejected",
+		errors.New("unknown"): "permanent",
+
+		fmt.Errorf("wrap: %w", bulkhead.Error{Reason: "full"}): "bulkhead_rejected",
+	} {
+		if class := ErrorClass(err); class != expect {
+			t.Errorf("%v: expect class '%s', but got '%s'", err, expect, class)
+		}
+	}
+}
+
+// blockWriter blocks writing until unblock is closed.
+type blockWriter struct {
+	once    *sync.Once
+	writing chan struct{}
+	unblock chan struct{}
+}
+
+func (w blockWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.unblock
+	return len(p), nil
+}
+
+func TestMetricsSlowWriter(t *testing.T) {
+	m := NewMetrics(Config{})
+	d := New(0, m, nil).Driver(driver.New("test", "test", func(context.Context, driver.Message) error { return nil }, nil))
+	for i := range 100 { // Make the output larger than the buffer.
+		_ = d.Send(context.Background(), driver.Message{Name: fmt.Sprint(i)})
+	}
+
+	w := blockWriter{once: new(sync.Once), writing: make(chan struct{}), unblock: make(chan struct{})}
+	defer close(w.unblock)
+	go func() { _, _ = m.WriteTo(w) }()
+	<-w.writing
+
+	done := make(chan struct{})
+	go func() { _ = d.Send(context.Background(), driver.Message{Name: "a"}); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the slow writer blocks sending the messages")
+	}
+}
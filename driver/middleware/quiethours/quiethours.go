@@ -0,0 +1,176 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quiethours provides a driver middleware to hold back
+// the non-critical messages in the quiet hours, that's, do-not-disturb.
+package quiethours
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+)
+
+var now = time.Now
+
+// Action is the action to handle the message in the quiet hours.
+type Action int
+
+const (
+	// ActionDrop drops the message silently.
+	ActionDrop Action = iota
+
+	// ActionFail returns Error.
+	ActionFail
+
+	// ActionDefer defers the message until the quiet hours end.
+	ActionDefer
+)
+
+// String returns the string representation of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionDrop:
+		return "drop"
+	case ActionFail:
+		return "fail"
+	case ActionDefer:
+		return "defer"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+// Error is returned when the message is held back by the quiet hours.
+type Error struct {
+	Until time.Time // The time when the quiet hours end.
+	Err   error     // The context error if it is done while deferring.
+}
+
+// Error implements the interface error.
+func (e Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("quiethours: the message is in the quiet hours until %s: %s",
+			e.Until.Format(time.RFC3339), e.Err)
+	}
+	return fmt.Sprintf("quiethours: the message is in the quiet hours until %s", e.Until.Format(time.RFC3339))
+}
+
+// Unwrap returns the inner error.
+func (e Error) Unwrap() error { return e.Err }
+
+// Temporary always returns false so that the retry middleware does not wait
+// for the end of the quiet hours.
+func (e Error) Temporary() bool { return false }
+
+// RetryAfter returns the duration after which the quiet hours end.
+func (e Error) RetryAfter() time.Duration { return max(e.Until.Sub(now()), 0) }
+
+// Config is used to configure the quiet hours middleware.
+type Config struct {
+	// Lookup returns the schedule of the quiet hours of the message,
+	// such as by the receiver or channel. nil means no quiet hours.
+	//
+	// Required.
+	Lookup func(driver.Message) *Schedule
+
+	// Action is the action to handle the message in the quiet hours.
+	//
+	// Default: ActionDrop
+	Action Action
+
+	// Bypass reports whether the message bypasses the quiet hours.
+	//
+	// Default: SeverityAbove(DefaultSeverityKey, SeverityWarning)
+	Bypass func(driver.Message) bool
+
+	// Defer is used by ActionDefer to defer the message until the quiet
+	// hours end, such as putting it into a delayed queue.
+	//
+	// Default: wait in process until the quiet hours end or the context
+	// is done, and return Error immediately if the deadline of the context
+	// is earlier than the end.
+	Defer func(c context.Context, m driver.Message, until time.Time) error
+
+	// OnQuiet is called when the message is in the quiet hours,
+	// which may be used to log or record the metrics.
+	OnQuiet func(m driver.Message, action Action, until time.Time)
+}
+
+// New returns a new quiet hours middleware, which handles the message
+// falling into the quiet hours of its schedule by the action, unless
+// it is allowed to bypass, such as the critical messages.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.Lookup == nil {
+		panic("quiethours: Lookup must not be nil")
+	}
+	if config.Bypass == nil {
+		config.Bypass = SeverityAbove(DefaultSeverityKey, SeverityWarning)
+	}
+
+	return middleware.NewWithMatch("quiethours", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) error {
+			s := config.Lookup(m)
+			if s == nil || config.Bypass(m) {
+				return d.Send(c, m)
+			}
+
+			until, quiet := s.Until(now())
+			if !quiet {
+				return d.Send(c, m)
+			}
+
+			if config.OnQuiet != nil {
+				config.OnQuiet(m, config.Action, until)
+			}
+
+			switch config.Action {
+			case ActionDrop:
+				return nil
+
+			case ActionDefer:
+				if config.Defer != nil {
+					return config.Defer(c, m, until)
+				}
+				if err := wait(c, until); err != nil {
+					return err
+				}
+				return d.Send(c, m)
+
+			default:
+				return Error{Until: until}
+			}
+		})
+	})
+}
+
+func wait(c context.Context, until time.Time) error {
+	delay := until.Sub(now())
+	if deadline, ok := c.Deadline(); ok && time.Until(deadline) < delay {
+		return Error{Until: until}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.Done():
+		return Error{Until: until, Err: c.Err()}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quiethours
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestSchedule(t *testing.T) {
+	s, err := NewSchedule("Asia/Shanghai",
+		Window{Start: 22 * time.Hour, End: 8 * time.Hour},
+		Window{Days: []time.Weekday{time.Saturday}, Start: 8 * time.Hour, End: 12 * time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc := s.Location
+	for _, c := range []struct {
+		t     time.Time
+		end   time.Time
+		quiet bool
+	}{
+		{t: time.Date(2025, 3, 5, 21, 0, 0, 0, loc)},
+		{t: time.Date(2025, 3, 5, 23, 0, 0, 0, loc), end: time.Date(2025, 3, 6, 8, 0, 0, 0, loc), quiet: true},
+		{t: time.Date(2025, 3, 6, 7, 0, 0, 0, loc), end: time.Date(2025, 3, 6, 8, 0, 0, 0, loc), quiet: true},
+		{t: time.Date(2025, 3, 6, 8, 0, 0, 0, loc)},
+		{t: time.Date(2025, 3, 8, 7, 0, 0, 0, loc), end: time.Date(2025, 3, 8, 12, 0, 0, 0, loc), quiet: true}, // Saturday
+		{t: time.Date(2025, 3, 5, 15, 30, 0, 0, time.UTC), end: time.Date(2025, 3, 6, 8, 0, 0, 0, loc), quiet: true},
+	} {
+		end, quiet := s.Until(c.t)
+		if quiet != c.quiet || !end.Equal(c.end) {
+			t.Errorf("%s: expect %v/%s, but got %v/%s", c.t, c.quiet, c.end, quiet, end)
+		}
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 3, 5, 23, 0, 0, 0, time.UTC) }
+
+	s := &Schedule{Windows: []Window{{Start: 22 * time.Hour, End: 8 * time.Hour}}}
+	lookup := LookupMap(func(m driver.Message) string { return m.Receiver }, map[string]*Schedule{"a": s})
+
+	var sent int
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { sent++; return nil }, nil)
+
+	drop := New(0, Config{Lookup: lookup}, nil).Driver(d)
+	_ = drop.Send(context.Background(), driver.Message{Receiver: "a"})
+	_ = drop.Send(context.Background(), driver.Message{Receiver: "b"})
+	_ = drop.Send(context.Background(), driver.Message{Receiver: "a", Metadata: map[string]any{"Severity": "critical"}})
+	if sent != 2 {
+		t.Errorf("expect %d messages sent, but got %d", 2, sent)
+	}
+
+	var qerr Error
+	fail := New(0, Config{Lookup: lookup, Action: ActionFail}, nil).Driver(d)
+	if err := fail.Send(context.Background(), driver.Message{Receiver: "a"}); !errors.As(err, &qerr) {
+		t.Errorf("expect a quiet hours error, but got %v", err)
+	} else if expect := time.Date(2025, 3, 6, 8, 0, 0, 0, time.UTC); !qerr.Until.Equal(expect) {
+		t.Errorf("expect the end time %s, but got %s", expect, qerr.Until)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	deferred := New(0, Config{Lookup: lookup, Action: ActionDefer}, nil).Driver(d)
+	if err := deferred.Send(ctx, driver.Message{Receiver: "a"}); !errors.As(err, &qerr) {
+		t.Errorf("expect a quiet hours error, but got %v", err)
+	}
+}
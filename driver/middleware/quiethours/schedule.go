@@ -0,0 +1,119 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quiethours
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+// Window is a window of the quiet hours in a week.
+//
+// Start and End are the offsets since the midnight in the wall clock,
+// such as 22*time.Hour for 22:00. If End is not after Start, the window
+// crosses the midnight and ends at End of the next day.
+type Window struct {
+	// Days is the days of the week when the window starts.
+	//
+	// Default: every day
+	Days []time.Weekday
+
+	Start time.Duration
+	End   time.Duration
+}
+
+// Schedule is the weekly schedule of the quiet hours in a time zone.
+type Schedule struct {
+	// Default: time.UTC
+	Location *time.Location
+
+	Windows []Window
+}
+
+// NewSchedule returns a new schedule in the IANA time zone,
+// such as "Asia/Shanghai".
+func NewSchedule(timezone string, windows ...Window) (*Schedule, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range windows {
+		if w.Start < 0 || w.Start >= 24*time.Hour || w.End < 0 || w.End > 24*time.Hour {
+			return nil, fmt.Errorf("quiethours: invalid window %+v", w)
+		}
+	}
+
+	return &Schedule{Location: loc, Windows: windows}, nil
+}
+
+// Until reports whether t is in the quiet hours, and returns the time
+// when they end, which also covers the overlapped or adjacent windows.
+func (s *Schedule) Until(t time.Time) (end time.Time, quiet bool) {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t = t.In(loc)
+	for range 8 { // Bound the chained windows, at most a week.
+		_end, ok := s.find(t)
+		if !ok {
+			break
+		}
+		end, quiet, t = _end, true, _end
+	}
+	return
+}
+
+func (s *Schedule) find(t time.Time) (end time.Time, ok bool) {
+	year, month, day := t.Date()
+	for _, w := range s.Windows {
+		// The window starting yesterday may cross the midnight into today.
+		for _, offset := range [...]int{0, -1} {
+			startday := time.Date(year, month, day+offset, 0, 0, 0, 0, t.Location())
+			if len(w.Days) > 0 && !slices.Contains(w.Days, startday.Weekday()) {
+				continue
+			}
+
+			endday := day + offset
+			if w.End <= w.Start {
+				endday++
+			}
+
+			start := time.Date(year, month, day+offset, 0, 0, 0, int(w.Start), t.Location())
+			_end := time.Date(year, month, endday, 0, 0, 0, int(w.End), t.Location())
+			if !t.Before(start) && t.Before(_end) && _end.After(end) {
+				end, ok = _end, true
+			}
+		}
+	}
+	return
+}
+
+// LookupMap returns a lookup function to look up the schedule from schedules
+// by the key of the message, such as the receiver or the channel name.
+// If not found, fall back to the schedule of the empty key.
+func LookupMap(key func(driver.Message) string, schedules map[string]*Schedule) func(driver.Message) *Schedule {
+	return func(m driver.Message) *Schedule {
+		if s, ok := schedules[key(m)]; ok {
+			return s
+		}
+		return schedules[""]
+	}
+}
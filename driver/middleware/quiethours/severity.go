@@ -0,0 +1,78 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quiethours
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+// DefaultSeverityKey is the default metadata key of the message severity.
+const DefaultSeverityKey = "Severity"
+
+// The pre-defined severities, which are compatible with the levels of slog.
+const (
+	SeverityDebug    = -4
+	SeverityInfo     = 0
+	SeverityWarning  = 4
+	SeverityError    = 8
+	SeverityCritical = 12
+)
+
+// Severities is the severities by the name, which is used by ParseSeverity.
+var Severities = map[string]int{
+	"debug":    SeverityDebug,
+	"info":     SeverityInfo,
+	"warn":     SeverityWarning,
+	"warning":  SeverityWarning,
+	"error":    SeverityError,
+	"critical": SeverityCritical,
+}
+
+// ParseSeverity parses the severity from an integer, or a string
+// of an integer or a name in Severities, which is case-insensitive.
+func ParseSeverity(v any) (severity int, ok bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64: // For JSON
+		return int(v), true
+	case string:
+		if severity, ok = Severities[strings.ToLower(v)]; ok {
+			return
+		}
+		if i, err := strconv.Atoi(v); err == nil {
+			return i, true
+		}
+	}
+	return
+}
+
+// SeverityAbove returns a bypass function which reports whether the severity
+// in the metadata of the message by the key is above the threshold.
+//
+// The message without the valid severity does not bypass.
+func SeverityAbove(key string, threshold int) func(driver.Message) bool {
+	return func(m driver.Message) bool {
+		severity, ok := ParseSeverity(m.Metadata[key])
+		return ok && severity > threshold
+	}
+}
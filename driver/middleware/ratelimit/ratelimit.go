@@ -0,0 +1,278 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit provides a driver middleware to limit the rate
+// of sending the messages by the token buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+)
+
+// Limit is a token bucket, which allows Count messages per Per,
+// and at most Burst messages at once.
+type Limit struct {
+	Count int
+	Per   time.Duration
+
+	// Default: Count
+	Burst int
+}
+
+// Config is used to configure the rate limit middleware.
+type Config struct {
+	// Limits is the token buckets of each key, all of which must allow the message.
+	// For example, the feishu bot allows 5 messages per second and 100 per minute.
+	//
+	// Required.
+	Limits []Limit
+
+	// Key returns the key of the message to select the token buckets.
+	//
+	// Default: Keys(ByType, ByReceiver)
+	Key func(driver.Message) string
+
+	// Wait reports whether to wait for the token until the context is done.
+	// If false, return Error immediately when the message is rate-limited.
+	// Even if true, return Error immediately if the deadline of the context
+	// is earlier than the time when the token is available.
+	Wait bool
+
+	// IdleTimeout is the duration after which the idle buckets of a key are evicted.
+	// But they are not evicted until all of them have been refilled fully,
+	// so that the eviction never resets the limits, such as 100 per day.
+	//
+	// Default: 10m
+	IdleTimeout time.Duration
+}
+
+// Error is returned when the message is rate-limited.
+type Error struct {
+	Key   string
+	Delay time.Duration // The duration after which the token is available.
+}
+
+// Error implements the interface error.
+func (e Error) Error() string {
+	return fmt.Sprintf("ratelimit: the message is rate-limited, retry after %s", e.Delay)
+}
+
+// Temporary returns true, because the bucket is refilled over time,
+// so the message may be sent after Delay.
+func (e Error) Temporary() bool { return true }
+
+// RetryAfter returns the duration after which the token is available.
+func (e Error) RetryAfter() time.Duration { return e.Delay }
+
+// ByChannel returns the channel name of the message as the key.
+func ByChannel(m driver.Message) string { return m.Name }
+
+// ByType returns the driver type of the message as the key.
+func ByType(m driver.Message) string { return m.Type }
+
+// ByReceiver returns the receiver of the message as the key.
+func ByReceiver(m driver.Message) string { return m.Receiver }
+
+// Keys returns a key function to join the keys returned by the key functions.
+func Keys(keys ...func(driver.Message) string) func(driver.Message) string {
+	if len(keys) == 0 {
+		panic("ratelimit.Keys: no key functions")
+	}
+
+	return func(m driver.Message) string {
+		ss := make([]string, len(keys))
+		for i, key := range keys {
+			ss[i] = key(m)
+		}
+		return strings.Join(ss, "\x00")
+	}
+}
+
+// New returns a new rate limit middleware, which limits the rate of sending
+// the messages with the same key by the token buckets.
+//
+// All the drivers wrapped by the middleware share the token buckets.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if len(config.Limits) == 0 {
+		panic("ratelimit: no limits")
+	}
+
+	limits := make([]limit, len(config.Limits))
+	for i, l := range config.Limits {
+		if l.Count <= 0 || l.Per <= 0 || l.Burst < 0 {
+			panic(fmt.Errorf("ratelimit: invalid limit %+v", l))
+		}
+		if l.Burst == 0 {
+			l.Burst = l.Count
+		}
+		limits[i] = limit{rate: float64(l.Count) / l.Per.Seconds(), burst: float64(l.Burst)}
+	}
+
+	if config.Key == nil {
+		config.Key = Keys(ByType, ByReceiver)
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 10 * time.Minute
+	}
+
+	l := &limiter{
+		limits:  limits,
+		idle:    config.IdleTimeout,
+		entries: make(map[string]*entry, 16),
+	}
+
+	return middleware.NewWithMatch("ratelimit", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) error {
+			if err := l.Wait(c, config.Key(m), config.Wait); err != nil {
+				return err
+			}
+			return d.Send(c, m)
+		})
+	})
+}
+
+type limit struct {
+	rate  float64 // The tokens per second.
+	burst float64
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type entry struct {
+	buckets []bucket
+	used    time.Time
+}
+
+type limiter struct {
+	limits []limit
+	idle   time.Duration
+
+	lock    sync.Mutex
+	swept   time.Time
+	entries map[string]*entry
+}
+
+// Wait takes a token from each bucket of the key.
+func (l *limiter) Wait(c context.Context, key string, wait bool) error {
+	delay, ok := l.reserve(key, time.Now(), wait)
+	if !ok {
+		return Error{Key: key, Delay: delay}
+	} else if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := c.Deadline(); ok && time.Until(deadline) < delay {
+		l.cancel(key)
+		return Error{Key: key, Delay: delay}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.Done():
+		l.cancel(key)
+		return c.Err()
+	}
+}
+
+// reserve takes a token from each bucket of the key, and returns the delay
+// after which the tokens are available.
+//
+// If wait is false, it does not take the tokens and returns false
+// when any token is not available now.
+func (l *limiter) reserve(key string, now time.Time, wait bool) (delay time.Duration, ok bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(now)
+	e := l.entries[key]
+	if e == nil {
+		e = &entry{buckets: make([]bucket, len(l.limits))}
+		for i := range e.buckets {
+			e.buckets[i] = bucket{tokens: l.limits[i].burst, last: now}
+		}
+		l.entries[key] = e
+	}
+	e.used = now
+
+	for i := range e.buckets {
+		b, limit := &e.buckets[i], l.limits[i]
+		b.tokens = min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+		b.last = now
+
+		if b.tokens < 1 {
+			delay = max(delay, time.Duration((1-b.tokens)/limit.rate*float64(time.Second)))
+		}
+	}
+
+	if delay > 0 && !wait {
+		return delay, false
+	}
+
+	for i := range e.buckets {
+		e.buckets[i].tokens--
+	}
+	return delay, true
+}
+
+// cancel gives back the tokens reserved but not used.
+func (l *limiter) cancel(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if e := l.entries[key]; e != nil {
+		for i := range e.buckets {
+			e.buckets[i].tokens = min(l.limits[i].burst, e.buckets[i].tokens+1)
+		}
+	}
+}
+
+// sweep evicts the idle entries whose buckets have been refilled fully,
+// which is done once per idle timeout at most.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.idle {
+		return
+	}
+
+	l.swept = now
+	for key, e := range l.entries {
+		if now.Sub(e.used) >= l.idle && l.full(e, now) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// full reports whether all the buckets of the entry are full at now,
+// that's, the entry is the same as a new one.
+func (l *limiter) full(e *entry, now time.Time) bool {
+	for i, b := range e.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limits[i].rate < l.limits[i].burst {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestLimiter(t *testing.T) {
+	l := &limiter{
+		limits: []limit{
+			{rate: 5, burst: 5},          // 5/s
+			{rate: 100.0 / 60, burst: 8}, // 100/m with the burst 8
+		},
+		idle:    time.Minute,
+		entries: make(map[string]*entry),
+	}
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, ok := l.reserve("a", now, false); !ok {
+			t.Fatalf("%d: expect to allow, but not", i)
+		}
+	}
+
+	if delay, ok := l.reserve("a", now, false); ok {
+		t.Errorf("expect to limit, but not")
+	} else if delay != 200*time.Millisecond {
+		t.Errorf("expect the delay %s, but got %s", 200*time.Millisecond, delay)
+	}
+
+	if _, ok := l.reserve("b", now, false); !ok {
+		t.Errorf("expect to allow the key b, but not")
+	}
+
+	// The first bucket is refilled, but the second only has 3+5/3 tokens.
+	now = now.Add(time.Second)
+	for i := 0; i < 4; i++ {
+		if _, ok := l.reserve("a", now, false); !ok {
+			t.Fatalf("%d: expect to allow, but not", i)
+		}
+	}
+	if delay, ok := l.reserve("a", now, true); !ok {
+		t.Errorf("expect to reserve, but not")
+	} else if delay < 190*time.Millisecond || delay > 210*time.Millisecond {
+		t.Errorf("expect the delay about %s, but got %s", 200*time.Millisecond, delay)
+	}
+
+	l.sweep(now.Add(time.Minute))
+	if len(l.entries) != 0 {
+		t.Errorf("expect no entries, but got %d", len(l.entries))
+	}
+
+	// The idle entry is not evicted until its buckets are refilled fully.
+	l = &limiter{limits: []limit{{rate: 1.0 / 3600, burst: 1}}, idle: time.Minute, entries: make(map[string]*entry)}
+	if _, ok := l.reserve("a", now, false); !ok {
+		t.Fatal("expect to allow, but not")
+	}
+
+	l.sweep(now.Add(2 * time.Minute))
+	if _, ok := l.reserve("a", now.Add(2*time.Minute), false); ok {
+		t.Errorf("expect to limit after the sweep, but not")
+	}
+
+	l.sweep(now.Add(2 * time.Hour))
+	if len(l.entries) != 0 {
+		t.Errorf("expect no entries, but got %d", len(l.entries))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var sent int
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { sent++; return nil }, nil)
+	d = New(0, Config{Limits: []Limit{{Count: 2, Per: time.Minute}}}, nil).Driver(d)
+
+	for i := range 2 {
+		if err := d.Send(context.Background(), driver.Message{Type: "test", Receiver: "a"}); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+	}
+
+	var rerr Error
+	err := d.Send(context.Background(), driver.Message{Type: "test", Receiver: "a"})
+	switch {
+	case !errors.As(err, &rerr):
+		t.Errorf("expect a ratelimit error, but got %v", err)
+	case rerr.Delay <= 0 || rerr.RetryAfter() != rerr.Delay || !rerr.Temporary():
+		t.Errorf("unexpected ratelimit error %+v", rerr)
+	}
+
+	if err := d.Send(context.Background(), driver.Message{Type: "test", Receiver: "b"}); err != nil {
+		t.Errorf("expect to allow the receiver b, but got %v", err)
+	}
+	if sent != 3 {
+		t.Errorf("expect %d messages sent, but got %d", 3, sent)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	var sent int
+	d := driver.New("test", "test", func(context.Context, driver.Message) error { sent++; return nil }, nil)
+	d = New(0, Config{Limits: []Limit{{Count: 1, Per: 50 * time.Millisecond}}, Wait: true}, nil).Driver(d)
+
+	start := time.Now()
+	for i := range 2 {
+		if err := d.Send(context.Background(), driver.Message{}); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+	}
+	if cost := time.Since(start); cost < 40*time.Millisecond {
+		t.Errorf("expect to wait for the token, but cost %s", cost)
+	}
+
+	// The deadline is earlier than the time when the token is available.
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var rerr Error
+	if err := d.Send(c, driver.Message{}); !errors.As(err, &rerr) {
+		t.Errorf("expect a ratelimit error, but got %v", err)
+	}
+
+	// The context is canceled during the wait.
+	c, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := d.Send(c, driver.Message{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expect the error context.Canceled, but got %v", err)
+	}
+
+	// The tokens are given back, so no more wait than one token.
+	start = time.Now()
+	if err := d.Send(context.Background(), driver.Message{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if cost := time.Since(start); cost > 70*time.Millisecond {
+		t.Errorf("expect to wait for one token at most, but cost %s", cost)
+	}
+
+	if sent != 3 {
+		t.Errorf("expect %d messages sent, but got %d", 3, sent)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recovery provides a driver middleware to recover the panic
+// when sending the message and convert it to an error.
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+)
+
+// PanicError is returned when the driver panics.
+type PanicError struct {
+	Channel string // The channel name of the message.
+	Driver  string // The name of the driver.
+	Value   any    // The value passed to panic.
+	Stack   []byte // The stack trace of the panic.
+}
+
+// Error implements the interface error.
+func (e PanicError) Error() string {
+	return fmt.Sprintf("the driver '%s' of the channel '%s' panics: %v", e.Driver, e.Channel, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Config is used to configure the recover middleware.
+type Config struct {
+	// Logger is used to log the panic with the stack trace.
+	//
+	// Default: slog.Default()
+	Logger *slog.Logger
+
+	// DisableLog disables to log the panic.
+	DisableLog bool
+}
+
+// New returns a new recover middleware, which recovers the panic in Send
+// of the driver and returns it as PanicError.
+//
+// It should be installed as the outermost middleware to cover the others,
+// such as by the field Outermost of the channel manager.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	return middleware.NewWithMatch("recover", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					perr := PanicError{Channel: m.Name, Driver: d.Name(), Value: r, Stack: debug.Stack()}
+					if !config.DisableLog {
+						logger := config.Logger
+						if logger == nil {
+							logger = slog.Default()
+						}
+
+						logger.ErrorContext(c, "the driver panics when sending the message",
+							slog.String("channel", perr.Channel), slog.String("driver", perr.Driver),
+							slog.Any("panic", perr.Value), slog.String("stack", string(perr.Stack)))
+					}
+					err = perr
+				}
+			}()
+
+			return d.Send(c, m)
+		})
+	})
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recovery
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+func TestRecover(t *testing.T) {
+	mw := New(0, Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}, nil)
+
+	var value any
+	d := mw.Driver(driver.New("test", "test", func(context.Context, driver.Message) error {
+		if value != nil {
+			panic(value)
+		}
+		return nil
+	}, nil))
+
+	if err := d.Send(context.Background(), driver.Message{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var perr PanicError
+	value = io.EOF
+	err := d.Send(context.Background(), driver.Message{Name: "channel"})
+	if !errors.As(err, &perr) {
+		t.Fatalf("expect a PanicError, but got %v", err)
+	}
+
+	if perr.Channel != "channel" || perr.Driver != "test" || len(perr.Stack) == 0 {
+		t.Errorf("unexpected panic error: %+v", perr)
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expect the panic value io.EOF, but got %v", perr.Value)
+	}
+}
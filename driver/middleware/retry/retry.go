@@ -0,0 +1,164 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry provides a driver middleware to retry sending the message
+// when it fails transiently.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+)
+
+// Config is used to configure the retry middleware.
+type Config struct {
+	// MaxAttempts is the maximum number of the attempts including the first one.
+	//
+	// Default: 3
+	MaxAttempts int
+
+	// BaseDelay is the maximum delay before the first retry,
+	// which is doubled for each next retry.
+	//
+	// Default: 100ms
+	BaseDelay time.Duration
+
+	// MaxDelay is the cap of the delay between two attempts.
+	//
+	// Default: 10s
+	MaxDelay time.Duration
+
+	// Classifier reports whether the error is transient and should be retried.
+	//
+	// Default: IsTransient
+	Classifier func(error) bool
+}
+
+// New returns a new retry middleware, which retries sending the message
+// with the capped exponential backoff and full jitter, that's, the delay
+// before the n-th retry is a random duration in [0, min(MaxDelay, BaseDelay*2^(n-1))).
+//
+// If the error carries a retry-after hint, that's, it or an error in its chain
+// implements the interface { RetryAfter() time.Duration } and returns a positive
+// duration, use it as the delay instead, which is also capped by MaxDelay.
+//
+// It does not retry any more if the context is done, or the delay exceeds
+// the deadline of the context. So, if the timeout middleware is outside of it,
+// the timeout covers all the attempts; or, each attempt has its own timeout.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = 100 * time.Millisecond
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 10 * time.Second
+	}
+	if config.Classifier == nil {
+		config.Classifier = IsTransient
+	}
+
+	return middleware.NewWithMatch("retry", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
+			for attempt := 1; ; attempt++ {
+				if err = d.Send(c, m); err == nil || attempt >= config.MaxAttempts ||
+					c.Err() != nil || !config.Classifier(err) {
+					return
+				}
+
+				if !sleep(c, config.delay(attempt, err)) {
+					return
+				}
+			}
+		})
+	})
+}
+
+func (c Config) delay(attempt int, err error) time.Duration {
+	var hint interface{ RetryAfter() time.Duration }
+	if errors.As(err, &hint) {
+		if delay := hint.RetryAfter(); delay > 0 {
+			return min(delay, c.MaxDelay)
+		}
+	}
+
+	backoff := c.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		if d := c.BaseDelay << shift; d > 0 && d < backoff {
+			backoff = d
+		}
+	}
+	return rand.N(backoff)
+}
+
+// sleep waits for the delay, and reports false if the context is done
+// or its deadline is earlier than the end of the delay.
+func sleep(c context.Context, delay time.Duration) bool {
+	if deadline, ok := c.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-c.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// IsTransient is the default classifier, which reports whether the error
+// is transient, that's,
+//
+//   - it or an error in its chain implements the interface { Temporary() bool },
+//     and returns true, such as the error of the http status code 429 or 5xx;
+//   - it is a network error or the connection is closed unexpectedly;
+//   - it is an SMTP reply error with the code 4xx.
+//
+// The context errors, context.Canceled and context.DeadlineExceeded,
+// are never transient. And the error whose Temporary returns false
+// takes precedence over the network error that it wraps, such as
+// the email error after DATA, which may have been delivered.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var temp interface{ Temporary() bool }
+	if errors.As(err, &temp) {
+		// net.Error also implements Temporary, which is deprecated.
+		if _, ok := temp.(net.Error); !ok {
+			return temp.Temporary()
+		}
+	}
+
+	var smtperr *textproto.Error
+	if errors.As(err, &smtperr) {
+		return smtperr.Code >= 400 && smtperr.Code < 500
+	}
+
+	var neterr net.Error
+	return errors.As(err, &neterr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
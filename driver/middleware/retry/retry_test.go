@@ -0,0 +1,129 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/xgfone/go-msgnotice/driver"
+)
+
+type tempError bool
+
+func (e tempError) Error() string   { return "temporary error" }
+func (e tempError) Temporary() bool { return bool(e) }
+
+type permError struct{ err error }
+
+func (e permError) Error() string   { return "permanent: " + e.err.Error() }
+func (e permError) Unwrap() error   { return e.err }
+func (e permError) Temporary() bool { return false }
+
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string             { return "retry after" }
+func (e retryAfterError) Temporary() bool           { return true }
+func (e retryAfterError) RetryAfter() time.Duration { return time.Duration(e) }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{nil, false},
+		{errors.New("error"), false},
+		{context.Canceled, false},
+		{fmt.Errorf("wrap: %w", context.DeadlineExceeded), false},
+		{tempError(true), true},
+		{fmt.Errorf("wrap: %w", tempError(false)), false},
+		{permError{&net.OpError{Op: "read", Err: io.EOF}}, false},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&textproto.Error{Code: 451, Msg: "try again later"}, true},
+		{&textproto.Error{Code: 550, Msg: "no such user"}, false},
+	}
+
+	for i, test := range tests {
+		if result := IsTransient(test.err); result != test.expect {
+			t.Errorf("%d: expect %v, but got %v: %v", i, test.expect, result, test.err)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	config := Config{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	var calls int
+	var errs []error
+	d := driver.New("test", "test", func(context.Context, driver.Message) (err error) {
+		if calls < len(errs) {
+			err = errs[calls]
+		}
+		calls++
+		return
+	}, nil)
+
+	errs = []error{tempError(true), tempError(true)}
+	if err := New(0, config, nil).Driver(d).Send(context.Background(), driver.Message{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if calls != 3 {
+		t.Errorf("expect %d calls, but got %d", 3, calls)
+	}
+
+	calls, errs = 0, []error{tempError(true), tempError(true), tempError(true), nil}
+	if err := New(0, config, nil).Driver(d).Send(context.Background(), driver.Message{}); err == nil {
+		t.Errorf("expect an error, but got nil")
+	} else if calls != 3 {
+		t.Errorf("expect %d calls, but got %d", 3, calls)
+	}
+
+	calls, errs = 0, []error{tempError(false), nil}
+	if err := New(0, config, nil).Driver(d).Send(context.Background(), driver.Message{}); err == nil {
+		t.Errorf("expect an error, but got nil")
+	} else if calls != 1 {
+		t.Errorf("expect %d calls, but got %d", 1, calls)
+	}
+
+	// The retry-after hint exceeds the deadline of the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls, errs = 0, []error{retryAfterError(time.Second), nil}
+	start := time.Now()
+	if err := New(0, Config{MaxDelay: time.Minute}, nil).Driver(d).Send(ctx, driver.Message{}); err == nil {
+		t.Errorf("expect an error, but got nil")
+	} else if calls != 1 {
+		t.Errorf("expect %d calls, but got %d", 1, calls)
+	} else if cost := time.Since(start); cost > 40*time.Millisecond {
+		t.Errorf("expect to return immediately, but cost %s", cost)
+	}
+
+	// The retry-after hint is capped by MaxDelay.
+	calls, errs = 0, []error{retryAfterError(time.Hour), nil}
+	start = time.Now()
+	if err := New(0, config, nil).Driver(d).Send(context.Background(), driver.Message{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if calls != 2 {
+		t.Errorf("expect %d calls, but got %d", 2, calls)
+	} else if cost := time.Since(start); cost > 40*time.Millisecond {
+		t.Errorf("expect to retry after MaxDelay, but cost %s", cost)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides a driver middleware to trace sending the message
+// by OpenTelemetry.
+package tracing
+
+import (
+	"context"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"github.com/xgfone/go-msgnotice/driver/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "github.com/xgfone/go-msgnotice/driver/middleware/tracing"
+
+// The attribute keys of the span.
+const (
+	AttrChannel       = attribute.Key("msgnotice.channel")
+	AttrDriverName    = attribute.Key("msgnotice.driver.name")
+	AttrDriverType    = attribute.Key("msgnotice.driver.type")
+	AttrReceiverCount = attribute.Key("msgnotice.receiver.count")
+	AttrOutcome       = attribute.Key("msgnotice.outcome")
+)
+
+// Config is used to configure the tracing middleware.
+type Config struct {
+	// Default: otel.GetTracerProvider()
+	TracerProvider trace.TracerProvider
+
+	// ReceiverCount returns the number of the receivers of the message.
+	//
+	// Default: the number of the receivers split by driver.SplitReceivers.
+	ReceiverCount func(driver.Message) int
+}
+
+func countReceivers(m driver.Message) int {
+	return len(driver.SplitReceivers(m.Receiver))
+}
+
+// New returns a new tracing middleware, which starts a span named
+// "msgnotice.send" for each message, with the attributes of the channel,
+// driver name and type, receiver count and outcome, "success" or "failure".
+// If it fails, the error is recorded into the span.
+//
+// The span is the child of the span in the context, and the drivers based on
+// the http, such as "feishu.webhook" and "sendgrid", propagate the trace context
+// in the outbound requests by the global propagator, otel.GetTextMapPropagator,
+// which should be set by otel.SetTextMapPropagator, such as the W3C trace context.
+func New(priority int, config Config, matcher driver.Matcher) middleware.Middleware {
+	if config.TracerProvider == nil {
+		config.TracerProvider = otel.GetTracerProvider()
+	}
+	if config.ReceiverCount == nil {
+		config.ReceiverCount = countReceivers
+	}
+
+	tracer := config.TracerProvider.Tracer(ScopeName)
+	return middleware.NewWithMatch("tracing", priority, matcher, func(d driver.Driver) driver.Driver {
+		return driver.Wrap(d, func(c context.Context, m driver.Message, d driver.Driver) (err error) {
+			c, span := tracer.Start(c, "msgnotice.send",
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					AttrChannel.String(m.Name),
+					AttrDriverName.String(d.Name()),
+					AttrDriverType.String(d.Type()),
+					AttrReceiverCount.Int(config.ReceiverCount(m)),
+				),
+			)
+			defer span.End()
+
+			if err = d.Send(c, m); err != nil {
+				span.SetAttributes(AttrOutcome.String("failure"))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else {
+				span.SetAttributes(AttrOutcome.String("success"))
+			}
+			return
+		})
+	})
+}
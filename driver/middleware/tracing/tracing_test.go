@@ -0,0 +1,118 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// testSpan records the span data, and the other methods do nothing.
+type testSpan struct {
+	trace.Span
+	name   string
+	kind   trace.SpanKind
+	attrs  map[attribute.Key]attribute.Value
+	errs   []error
+	status codes.Code
+	ended  bool
+}
+
+func (s *testSpan) End(...trace.SpanEndOption)                    { s.ended = true }
+func (s *testSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+func (s *testSpan) SetStatus(code codes.Code, _ string)           { s.status = code }
+func (s *testSpan) SetAttributes(kvs ...attribute.KeyValue) {
+	for _, kv := range kvs {
+		s.attrs[kv.Key] = kv.Value
+	}
+}
+
+type testTracer struct {
+	trace.Tracer
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(c context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	s := &testSpan{Span: trace.SpanFromContext(c), name: name, kind: config.SpanKind(), attrs: make(map[attribute.Key]attribute.Value)}
+	s.SetAttributes(config.Attributes()...)
+	t.spans = append(t.spans, s)
+	return trace.ContextWithSpan(c, s), s
+}
+
+type testTracerProvider struct {
+	trace.TracerProvider
+	tracer *testTracer
+}
+
+func (p testTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer { return p.tracer }
+
+func TestTracing(t *testing.T) {
+	tracer := &testTracer{Tracer: noop.NewTracerProvider().Tracer("")}
+	provider := testTracerProvider{TracerProvider: noop.NewTracerProvider(), tracer: tracer}
+
+	var spanInDriver trace.Span
+	d := driver.New("name", "type", func(c context.Context, m driver.Message) error {
+		spanInDriver = trace.SpanFromContext(c)
+		if m.Content == "fail" {
+			return errors.New("error")
+		}
+		return nil
+	}, nil)
+	d = New(0, Config{TracerProvider: provider}, nil).Driver(d)
+
+	_ = d.Send(context.Background(), driver.NewMessage("channel", "type", `"Doe, J" <j@example.com>, k@example.com`, "ok", nil))
+	_ = d.Send(context.Background(), driver.NewMessage("channel", "type", "a", "fail", nil))
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expect %d spans, but got %d", 2, len(tracer.spans))
+	}
+
+	success, failure := tracer.spans[0], tracer.spans[1]
+	if spanInDriver != failure {
+		t.Errorf("expect the span is passed to the driver by the context")
+	}
+
+	for _, s := range tracer.spans {
+		if s.name != "msgnotice.send" || s.kind != trace.SpanKindClient || !s.ended {
+			t.Errorf("unexpected span: name=%s, kind=%s, ended=%v", s.name, s.kind, s.ended)
+		}
+		for key, value := range map[attribute.Key]string{
+			AttrChannel:    "channel",
+			AttrDriverName: "name",
+			AttrDriverType: "type",
+		} {
+			if v := s.attrs[key].AsString(); v != value {
+				t.Errorf("%s: expect '%s', but got '%s'", key, value, v)
+			}
+		}
+	}
+
+	if n := success.attrs[AttrReceiverCount].AsInt64(); n != 2 {
+		t.Errorf("expect %d receivers, but got %d", 2, n)
+	}
+	if outcome := success.attrs[AttrOutcome].AsString(); outcome != "success" || success.status != codes.Unset {
+		t.Errorf("unexpected the success span: outcome=%s, status=%s", outcome, success.status)
+	}
+	if outcome := failure.attrs[AttrOutcome].AsString(); outcome != "failure" || failure.status != codes.Error || len(failure.errs) != 1 {
+		t.Errorf("unexpected the failure span: outcome=%s, status=%s, errs=%v", outcome, failure.status, failure.errs)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import "strings"
+
+// SplitReceivers splits the comma-separated receiver list into the non-empty
+// receivers with the surrounding whitespaces trimmed.
+//
+// The commas in the quoted strings, comments, angle brackets and groups
+// of RFC 5322 are not the separators, such as `"Doe, John" <john@example.com>`
+// and `team: a@example.com, b@example.com;`, so a group is one receiver.
+// A colon starts a group only if a semicolon follows it, so that the urls,
+// such as "https://example.com", are split as usual.
+func SplitReceivers(receiver string) (receivers []string) {
+	var quoted, escaped, group bool
+	var comments, angles int
+
+	start := 0
+	add := func(end int) {
+		if r := strings.TrimSpace(receiver[start:end]); r != "" {
+			receivers = append(receivers, r)
+		}
+		start = end + 1
+	}
+
+	for i := 0; i < len(receiver); i++ {
+		switch c := receiver[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && (quoted || comments > 0):
+			escaped = true
+		case quoted:
+			quoted = c != '"'
+		case c == '"':
+			quoted = true
+		case c == '(':
+			comments++
+		case c == ')' && comments > 0:
+			comments--
+		case comments > 0:
+		case c == '<':
+			angles++
+		case c == '>' && angles > 0:
+			angles--
+		case angles > 0:
+		case c == ':' && !group:
+			group = strings.IndexByte(receiver[i:], ';') > 0
+		case c == ';':
+			group = false
+		case c == ',' && !group:
+			add(i)
+		}
+	}
+	add(len(receiver))
+	return
+}
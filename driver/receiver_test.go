@@ -0,0 +1,42 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitReceivers(t *testing.T) {
+	for receiver, expect := range map[string][]string{
+		"":         nil,
+		" , ,":     nil,
+		"a, b,,c ": {"a", "b", "c"},
+
+		`"Doe, J" <j@example.com>, k@example.com`:        {`"Doe, J" <j@example.com>`, "k@example.com"},
+		`"Doe, \"J" <j@example.com>,k@example.com`:       {`"Doe, \"J" <j@example.com>`, "k@example.com"},
+		`j@example.com (Doe, J), +8613800001234`:         {"j@example.com (Doe, J)", "+8613800001234"},
+		`<j@example.com,k@example.com>, https://a/b?c=d`: {"<j@example.com,k@example.com>", "https://a/b?c=d"},
+
+		// Group
+		`team: a@example.com, "b;" <b@example.com>;, c@example.com`: {`team: a@example.com, "b;" <b@example.com>;`, "c@example.com"},
+		`team:;, a@example.com, https://a/b`:                        {"team:;", "a@example.com", "https://a/b"},
+		`https://a/b, https://c/d;e`:                                {"https://a/b, https://c/d;e"},
+	} {
+		if receivers := SplitReceivers(receiver); !reflect.DeepEqual(receivers, expect) {
+			t.Errorf("%q: expect %q, but got %q", receiver, expect, receivers)
+		}
+	}
+}
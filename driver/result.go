@@ -0,0 +1,69 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"sync"
+)
+
+type resultKey struct{}
+
+// Result is used to collect the result of sending the message,
+// such as the message ids returned by the provider.
+type Result struct {
+	lock sync.Mutex
+	ids  []string
+}
+
+// MessageIDs returns the message ids reported by the driver.
+func (r *Result) MessageIDs() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+func (r *Result) addMessageID(id string) {
+	r.lock.Lock()
+	r.ids = append(r.ids, id)
+	r.lock.Unlock()
+}
+
+// WithResult returns a new context carrying the result,
+// which is filled by the driver when sending the message.
+func WithResult(ctx context.Context, r *Result) context.Context {
+	if r == nil {
+		panic("driver.WithResult: result must not be nil")
+	}
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+// GetResult returns the result carried by the context.
+//
+// Return nil if not exist.
+func GetResult(ctx context.Context) *Result {
+	r, _ := ctx.Value(resultKey{}).(*Result)
+	return r
+}
+
+// AddMessageID adds the message id into the result carried by the context,
+// which is used by the driver to report the message id returned by the provider.
+//
+// It does nothing if the context does not carry the result or id is empty.
+func AddMessageID(ctx context.Context, id string) {
+	if r := GetResult(ctx); r != nil && id != "" {
+		r.addMessageID(id)
+	}
+}
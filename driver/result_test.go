@@ -0,0 +1,49 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestResult(t *testing.T) {
+	// No result in the context.
+	AddMessageID(context.Background(), "id0")
+	if r := GetResult(context.Background()); r != nil {
+		t.Errorf("expect nil result, but got %v", r)
+	}
+
+	var r Result
+	c := WithResult(context.Background(), &r)
+	if GetResult(c) != &r {
+		t.Errorf("expect the result carried by the context")
+	}
+
+	AddMessageID(c, "id1")
+	AddMessageID(c, "")
+	AddMessageID(c, "id2")
+
+	ids := r.MessageIDs()
+	if expect := []string{"id1", "id2"}; !reflect.DeepEqual(ids, expect) {
+		t.Errorf("expect message ids %v, but got %v", expect, ids)
+	}
+
+	ids[0] = "modified"
+	if id := r.MessageIDs()[0]; id != "id1" {
+		t.Errorf("the returned message ids share the internal slice")
+	}
+}
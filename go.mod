@@ -1,8 +1,24 @@
 module github.com/xgfone/go-msgnotice
 
 require (
+	github.com/ProtonMail/go-crypto v1.3.0
 	github.com/knadh/smtppool v1.3.0
+	github.com/smallstep/pkcs7 v0.2.1
 	github.com/xgfone/go-toolkit v0.8.0
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
+	golang.org/x/net v0.35.0
 )
 
-go 1.22
+require (
+	github.com/cloudflare/circl v1.6.0 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.35.0 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
+
+go 1.22.0
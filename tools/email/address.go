@@ -0,0 +1,89 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xgfone/go-msgnotice/driver"
+	"golang.org/x/net/idna"
+)
+
+// ParseAddressList parses the comma-separated address list by RFC 5322,
+// such as `"Doe, John" <john@example.com>, team: a@example.com, b@example.com;`,
+// which supports the display name, the group and the internationalized address.
+//
+// The non-ASCII domain is encoded to punycode, but the non-ASCII local part
+// is kept as it is, which requires the mail server to support SMTPUTF8.
+// The duplicated addresses are removed, and the returned error names
+// the invalid entry.
+func ParseAddressList(list string) (addrs []*mail.Address, err error) {
+	entries := driver.SplitReceivers(list)
+	addrs = make([]*mail.Address, 0, len(entries))
+	seen := make(map[string]struct{}, len(entries))
+
+	for _, entry := range entries {
+		list, err := mail.ParseAddressList(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address '%s': %w", entry, err)
+		}
+
+		for _, addr := range list {
+			if addr.Address, err = encodeAddressDomain(addr.Address); err != nil {
+				return nil, fmt.Errorf("invalid address '%s': %w", entry, err)
+			}
+
+			key := strings.ToLower(addr.Address)
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	return
+}
+
+// encodeAddressDomain encodes the non-ASCII domain of the address to punycode.
+func encodeAddressDomain(address string) (string, error) {
+	index := strings.LastIndexByte(address, '@')
+	if index < 0 {
+		return address, errors.New("missing '@'")
+	}
+
+	domain := address[index+1:]
+	if isASCII(domain) {
+		return address, nil
+	}
+
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return address, err
+	}
+	return address[:index+1] + domain, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
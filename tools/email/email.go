@@ -17,55 +17,178 @@ package email
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 
+	"github.com/xgfone/go-msgnotice/driver"
 	"github.com/xgfone/go-toolkit/jsonx"
 )
 
+// The content types of the email message.
+const (
+	ContentTypeHTML = "html"
+	ContentTypeText = "text"
+)
+
 // Message represents the email message.
 type Message struct {
 	Subject string
 	Content string
+
+	// Text is the plain text alternative of the html content.
+	//
+	// If empty, it is rendered from the html content by HTMLToText.
+	Text string `json:",omitempty"`
+
+	// ContentType is the type of Content, such as ContentTypeHTML or ContentTypeText.
+	//
+	// Default: ContentTypeHTML
+	ContentType string `json:",omitempty"`
+
+	// From is the sender of the email, such as "Name <user@example.com>",
+	// which must be allowed by the driver.
+	//
+	// If empty, use the sender configured by the driver.
+	From string `json:",omitempty"`
+
+	Cc      []string `json:",omitempty"`
+	Bcc     []string `json:",omitempty"`
+	ReplyTo []string `json:",omitempty"`
+
+	// Headers is the extra headers of the email, such as "X-Priority" and "List-Id".
+	Headers map[string]string `json:",omitempty"`
+
+	// ThreadKey is used to thread the emails with the same key in the email
+	// clients, such as the incident id, by the In-Reply-To and References headers.
+	ThreadKey string `json:",omitempty"`
+
+	// MessageKey is used to derive the stable Message-ID of the email,
+	// such as the idempotency key of the notification, so that the resent
+	// email has the same Message-ID and is deduplicated by the email clients.
+	//
+	// If empty, each sent email has a new random Message-ID.
+	MessageKey string `json:",omitempty"`
+
+	Attachments []Attachment `json:",omitempty"`
+}
+
+// ApplyMetadata applies the header fields in the message metadata,
+// which override those in the message.
+//
+// The supported keys are the same as the fields of Message, that's,
+// From(string), ThreadKey(string), MessageKey(string), Cc, Bcc and ReplyTo(a list of strings
+// or a comma-separated string), and Headers(map[string]string or map[string]any
+// with the string values).
+// For Headers, they are merged into the headers of the message.
+func (m *Message) ApplyMetadata(metadata map[string]any) (err error) {
+	var msg Message
+	if err = decodeHeaderFields(&msg, metadata); err != nil {
+		return
+	}
+
+	if msg.From != "" {
+		m.From = msg.From
+	}
+	if msg.ThreadKey != "" {
+		m.ThreadKey = msg.ThreadKey
+	}
+	if msg.MessageKey != "" {
+		m.MessageKey = msg.MessageKey
+	}
+	if msg.Cc != nil {
+		m.Cc = msg.Cc
+	}
+	if msg.Bcc != nil {
+		m.Bcc = msg.Bcc
+	}
+	if msg.ReplyTo != nil {
+		m.ReplyTo = msg.ReplyTo
+	}
+	if len(msg.Headers) > 0 {
+		headers := make(map[string]string, len(m.Headers)+len(msg.Headers))
+		for key, value := range m.Headers {
+			headers[key] = value
+		}
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		m.Headers = headers
+	}
+
+	return
+}
+
+// Attachment represents an attachment of the email message.
+type Attachment struct {
+	// Name is the file name of the attachment.
+	//
+	// If empty, use the base name of Path instead.
+	Name string
+
+	// ContentType is the MIME type of the attachment.
+	//
+	// If empty, guess it by the extension of Name.
+	ContentType string `json:",omitempty"`
+
+	// ContentID is the content id of the inline attachment, such as an image,
+	// which is referenced by "cid:ContentID" in the html content.
+	//
+	// If empty, the attachment is not inline.
+	ContentID string `json:",omitempty"`
+
+	// Content is the content of the attachment, which is encoded by base64 in JSON.
+	Content []byte `json:",omitempty"`
+
+	// Path is the path of the file as the content if Content is empty.
+	Path string `json:",omitempty"`
 }
 
 // DecodeMessage deocdes the message content and returns the subject and content.
+//
+// It is the same as Decode, but only returns the subject and content.
 func DecodeMessage(msgContent any) (subject, content string, err error) {
+	m, err := Decode(msgContent)
+	return m.Subject, m.Content, err
+}
+
+// Decode decodes the message content and returns the email message.
+//
+// msgContent supports the types as follow:
+//
+//	Message
+//	interface{ Message() Message }
+//	interface{ Message() (subject, content string) }
+//	map[string]any
+//	[]byte, json.RawMessage
+//
+// For map[string]any, Subject, Content, Text and ContentType are strings,
+// the header fields are the same as those of Message.ApplyMetadata,
+// and Attachments is a list of the attachments, each of which is a map[string]any
+// with the same fields as Attachment, and whose Content is a base64 string or []byte.
+func Decode(msgContent any) (m Message, err error) {
 	type messager interface {
 		Message() (subject, content string)
 	}
 
 	switch v := msgContent.(type) {
 	case Message:
-		subject, content = v.Subject, v.Content
+		m = v
 
 	case messager:
-		subject, content = v.Message()
+		m.Subject, m.Content = v.Message()
 
 	case interface{ Message() Message }:
-		m := v.Message()
-		subject, content = m.Subject, m.Content
+		m = v.Message()
 
 	case map[string]any:
-		var ok bool
-		if subject, ok = v["Subject"].(string); !ok {
-			err = fmt.Errorf("driver.email: 'Subject' expects a string, but got %T", v["Subject"])
-			return
-		}
-		if content, ok = v["Content"].(string); !ok {
-			err = fmt.Errorf("driver.email: 'Content' expects a string, but got %T", v["Content"])
-			return
-		}
+		m, err = decodeMap(v)
 
 	case []byte:
-		var m Message
 		err = jsonx.UnmarshalReader(&m, bytes.NewReader(v))
-		subject, content = m.Subject, m.Content
 
 	case json.RawMessage:
-		var m Message
 		err = jsonx.UnmarshalReader(&m, bytes.NewReader(v))
-		subject, content = m.Subject, m.Content
 
 	default:
 		err = fmt.Errorf("driver.email: unsupported content type %T", v)
@@ -73,3 +196,162 @@ func DecodeMessage(msgContent any) (subject, content string, err error) {
 
 	return
 }
+
+func decodeMap(v map[string]any) (m Message, err error) {
+	var ok bool
+	if m.Subject, ok = v["Subject"].(string); !ok {
+		err = fmt.Errorf("driver.email: 'Subject' expects a string, but got %T", v["Subject"])
+		return
+	}
+	if m.Content, ok = v["Content"].(string); !ok {
+		err = fmt.Errorf("driver.email: 'Content' expects a string, but got %T", v["Content"])
+		return
+	}
+	if m.Text, ok = v["Text"].(string); !ok && v["Text"] != nil {
+		err = fmt.Errorf("driver.email: 'Text' expects a string, but got %T", v["Text"])
+		return
+	}
+	if m.ContentType, ok = v["ContentType"].(string); !ok && v["ContentType"] != nil {
+		err = fmt.Errorf("driver.email: 'ContentType' expects a string, but got %T", v["ContentType"])
+		return
+	}
+	if err = decodeHeaderFields(&m, v); err != nil {
+		return
+	}
+
+	switch attachments := v["Attachments"].(type) {
+	case nil:
+	case []Attachment:
+		m.Attachments = attachments
+
+	case []any:
+		m.Attachments = make([]Attachment, len(attachments))
+		for i, a := range attachments {
+			if m.Attachments[i], err = decodeAttachment(a); err != nil {
+				err = fmt.Errorf("driver.email: Attachments[%d]: %w", i, err)
+				return
+			}
+		}
+
+	case []map[string]any:
+		m.Attachments = make([]Attachment, len(attachments))
+		for i, a := range attachments {
+			if m.Attachments[i], err = decodeAttachment(a); err != nil {
+				err = fmt.Errorf("driver.email: Attachments[%d]: %w", i, err)
+				return
+			}
+		}
+
+	default:
+		err = fmt.Errorf("driver.email: 'Attachments' expects a list, but got %T", attachments)
+	}
+
+	return
+}
+
+func decodeHeaderFields(m *Message, v map[string]any) (err error) {
+	var ok bool
+	if m.From, ok = v["From"].(string); !ok && v["From"] != nil {
+		return fmt.Errorf("driver.email: 'From' expects a string, but got %T", v["From"])
+	}
+	if m.ThreadKey, ok = v["ThreadKey"].(string); !ok && v["ThreadKey"] != nil {
+		return fmt.Errorf("driver.email: 'ThreadKey' expects a string, but got %T", v["ThreadKey"])
+	}
+	if m.MessageKey, ok = v["MessageKey"].(string); !ok && v["MessageKey"] != nil {
+		return fmt.Errorf("driver.email: 'MessageKey' expects a string, but got %T", v["MessageKey"])
+	}
+
+	for key, field := range map[string]*[]string{
+		"Cc":      &m.Cc,
+		"Bcc":     &m.Bcc,
+		"ReplyTo": &m.ReplyTo,
+	} {
+		if *field, err = decodeStrings(v[key]); err != nil {
+			return fmt.Errorf("driver.email: '%s' %w", key, err)
+		}
+	}
+
+	switch headers := v["Headers"].(type) {
+	case nil:
+	case map[string]string:
+		m.Headers = headers
+
+	case map[string]any:
+		m.Headers = make(map[string]string, len(headers))
+		for key, value := range headers {
+			if m.Headers[key], ok = value.(string); !ok {
+				return fmt.Errorf("driver.email: Headers['%s'] expects a string, but got %T", key, value)
+			}
+		}
+
+	default:
+		return fmt.Errorf("driver.email: 'Headers' expects a map, but got %T", headers)
+	}
+
+	return
+}
+
+func decodeStrings(v any) (ss []string, err error) {
+	switch vs := v.(type) {
+	case nil:
+	case []string:
+		ss = vs
+
+	case string:
+		ss = driver.SplitReceivers(vs)
+
+	case []any:
+		ss = make([]string, len(vs))
+		for i, s := range vs {
+			var ok bool
+			if ss[i], ok = s.(string); !ok {
+				return nil, fmt.Errorf("expects a list of strings, but got %T", s)
+			}
+		}
+
+	default:
+		err = fmt.Errorf("expects a list of strings, but got %T", vs)
+	}
+
+	return
+}
+
+func decodeAttachment(v any) (a Attachment, err error) {
+	if a, ok := v.(Attachment); ok {
+		return a, nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return a, fmt.Errorf("expect a map[string]any, but got %T", v)
+	}
+
+	for key, field := range map[string]*string{
+		"Name":        &a.Name,
+		"ContentType": &a.ContentType,
+		"ContentID":   &a.ContentID,
+		"Path":        &a.Path,
+	} {
+		switch value := m[key].(type) {
+		case nil:
+		case string:
+			*field = value
+		default:
+			return a, fmt.Errorf("'%s' expects a string, but got %T", key, value)
+		}
+	}
+
+	switch content := m["Content"].(type) {
+	case nil:
+	case []byte:
+		a.Content = content
+	case string:
+		if a.Content, err = base64.StdEncoding.DecodeString(content); err != nil {
+			return a, fmt.Errorf("invalid base64 'Content': %w", err)
+		}
+	default:
+		return a, fmt.Errorf("'Content' expects a base64 string, but got %T", content)
+	}
+
+	return
+}
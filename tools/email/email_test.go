@@ -0,0 +1,146 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	expect := Message{
+		Subject: "subject",
+		Content: `<img src="cid:logo">`,
+		Attachments: []Attachment{
+			{Name: "logo.png", ContentID: "logo", Content: []byte("logo")},
+			{Name: "report.csv", ContentType: "text/csv", Path: "report.csv"},
+		},
+	}
+
+	contents := []any{
+		expect,
+		map[string]any{
+			"Subject": "subject",
+			"Content": `<img src="cid:logo">`,
+			"Attachments": []any{
+				map[string]any{"Name": "logo.png", "ContentID": "logo", "Content": "bG9nbw=="},
+				map[string]any{"Name": "report.csv", "ContentType": "text/csv", "Path": "report.csv"},
+			},
+		},
+		json.RawMessage(`{"Subject":"subject","Content":"<img src=\"cid:logo\">","Attachments":[
+			{"Name":"logo.png","ContentID":"logo","Content":"bG9nbw=="},
+			{"Name":"report.csv","ContentType":"text/csv","Path":"report.csv"}
+		]}`),
+	}
+
+	for i, content := range contents {
+		msg, err := Decode(content)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		} else if !reflect.DeepEqual(expect, msg) {
+			t.Errorf("%d: expect %+v, but got %+v", i, expect, msg)
+		}
+	}
+
+	_, err := Decode(map[string]any{"Subject": "subject", "Content": "content", "Attachments": "invalid"})
+	if err == nil {
+		t.Errorf("expect an error, but got nil")
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	content := `<html><head><title>title</title><style>p{}</style></head><body>
+		<h1>Alert</h1>
+		<p>The service is <b>down</b>,<br>see <a href="https://example.com/a">details</a>.</p>
+		<ul><li>first</li><li>second</li></ul>
+		<table><tr><th>Name</th><th>Value</th></tr><tr><td>cpu</td><td>90%</td></tr></table>
+	</body></html>`
+
+	expect := "Alert\n\nThe service is down,\nsee details (https://example.com/a).\n\n" +
+		"- first\n- second\n\nName | Value\ncpu | 90%"
+	if text := HTMLToText(content); text != expect {
+		t.Errorf("expect %q, but got %q", expect, text)
+	}
+}
+
+func TestMessageApplyMetadata(t *testing.T) {
+	msg, err := Decode(map[string]any{
+		"Subject": "subject",
+		"Content": "content",
+		"Cc":      "a@example.com, b@example.com",
+		"Headers": map[string]any{"X-Priority": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = msg.ApplyMetadata(map[string]any{
+		"From":       "Bot <bot@example.com>",
+		"Bcc":        []any{"c@example.com"},
+		"Headers":    map[string]string{"List-Id": "<ops.example.com>"},
+		"ThreadKey":  "incident-1",
+		"MessageKey": "notice-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := Message{
+		Subject: "subject",
+		Content: "content",
+		From:    "Bot <bot@example.com>",
+		Cc:      []string{"a@example.com", "b@example.com"},
+		Bcc:     []string{"c@example.com"},
+		Headers: map[string]string{"X-Priority": "1", "List-Id": "<ops.example.com>"},
+
+		ThreadKey:  "incident-1",
+		MessageKey: "notice-1",
+	}
+	if !reflect.DeepEqual(expect, msg) {
+		t.Errorf("expect %+v, but got %+v", expect, msg)
+	}
+
+	if err = msg.ApplyMetadata(map[string]any{"Cc": 123}); err == nil {
+		t.Errorf("expect an error, but got nil")
+	}
+}
+
+func TestParseAddressList(t *testing.T) {
+	addrs, err := ParseAddressList(`"Doe, John" <john@example.com>, team: a@example.com, B <b@example.com>;,` +
+		` 张三 <zs@bücher.de>, A@example.com, 用户@例子.中国`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expects := []string{"john@example.com", "a@example.com", "b@example.com", "zs@xn--bcher-kva.de", "用户@xn--fsqu00a.xn--fiqs8s"}
+	if len(addrs) != len(expects) {
+		t.Fatalf("expect %d addresses, but got %d", len(expects), len(addrs))
+	}
+	for i, addr := range addrs {
+		if addr.Address != expects[i] {
+			t.Errorf("%d: expect address '%s', but got '%s'", i, expects[i], addr.Address)
+		}
+	}
+	if addrs[0].Name != "Doe, John" {
+		t.Errorf("expect name '%s', but got '%s'", "Doe, John", addrs[0].Name)
+	}
+
+	_, err = ParseAddressList("a@example.com, garbage")
+	if err == nil || !strings.Contains(err.Error(), "'garbage'") {
+		t.Errorf("expect an error naming 'garbage', but got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package email
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToText renders the html content to a readable plain text,
+// which is used as the plain text alternative of the html email.
+//
+// The links are rendered as "text (url)", the list items are prefixed
+// by "- " or the number, and the table rows are flattened into the lines
+// whose cells are separated by " | ".
+func HTMLToText(content string) string {
+	w := textWriter{lists: make([]int, 0, 4), cells: make([]int, 0, 4)}
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return w.String()
+
+		case html.TextToken:
+			if w.skip == 0 {
+				w.text(string(z.Text()))
+			}
+
+		case html.StartTagToken:
+			w.start(z, false)
+
+		case html.SelfClosingTagToken:
+			w.start(z, true)
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			w.end(atom.Lookup(name))
+		}
+	}
+}
+
+type link struct {
+	href  string
+	start int
+}
+
+type textWriter struct {
+	buf strings.Builder
+
+	newlines int  // The number of the pending newlines.
+	space    bool // Whether there is a pending space.
+	last     rune // The last written character.
+
+	skip  int // The depth of the elements whose content is skipped.
+	pre   int // The depth of the pre elements.
+	lists []int
+	cells []int
+	links []link
+}
+
+func (w *textWriter) String() string {
+	lines := strings.Split(w.buf.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func (w *textWriter) flush() {
+	switch {
+	case w.buf.Len() == 0:
+	case w.newlines > 0:
+		w.buf.WriteString(strings.Repeat("\n", min(w.newlines, 2)))
+		w.last = '\n'
+	case w.space && w.last != ' ' && w.last != '\n':
+		w.buf.WriteByte(' ')
+		w.last = ' '
+	}
+	w.newlines = 0
+	w.space = false
+}
+
+func (w *textWriter) write(s string) {
+	if s == "" {
+		return
+	}
+
+	w.flush()
+	w.buf.WriteString(s)
+	w.last = rune(s[len(s)-1])
+}
+
+func (w *textWriter) text(s string) {
+	if w.pre > 0 {
+		w.write(s)
+		return
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			w.space = true
+		} else {
+			w.flush()
+			w.buf.WriteRune(r)
+			w.last = r
+		}
+	}
+}
+
+func (w *textWriter) block(newlines int) {
+	w.newlines = max(w.newlines, newlines)
+	w.space = false
+}
+
+func (w *textWriter) start(z *html.Tokenizer, selfclosing bool) {
+	name, hasattr := z.TagName()
+	tag := atom.Lookup(name)
+
+	var attrs map[string]string
+	if hasattr {
+		attrs = make(map[string]string, 4)
+		for {
+			key, value, more := z.TagAttr()
+			attrs[string(key)] = string(value)
+			if !more {
+				break
+			}
+		}
+	}
+
+	switch tag {
+	case atom.Br:
+		if w.buf.Len() > 0 {
+			w.newlines++
+		}
+		return
+
+	case atom.Hr:
+		w.block(1)
+		w.write("--------")
+		w.block(1)
+		return
+
+	case atom.Img:
+		if w.skip == 0 {
+			w.text(attrs["alt"])
+		}
+		return
+	}
+
+	if selfclosing {
+		return
+	}
+
+	switch tag {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template, atom.Noscript:
+		w.skip++
+
+	case atom.Pre:
+		w.pre++
+		w.block(2)
+
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Table:
+		w.block(2)
+
+	case atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Tbody, atom.Thead, atom.Tfoot:
+		w.block(1)
+
+	case atom.Ul:
+		w.lists = append(w.lists, -1)
+		w.block(1)
+
+	case atom.Ol:
+		w.lists = append(w.lists, 0)
+		w.block(1)
+
+	case atom.Li:
+		w.block(1)
+		prefix := "- "
+		if n := len(w.lists); n > 0 {
+			if w.lists[n-1] >= 0 {
+				w.lists[n-1]++
+				prefix = strconv.FormatInt(int64(w.lists[n-1]), 10) + ". "
+			}
+			prefix = strings.Repeat("  ", n-1) + prefix
+		}
+		w.write(prefix)
+
+	case atom.Tr:
+		w.cells = append(w.cells, 0)
+		w.block(1)
+
+	case atom.Td, atom.Th:
+		if n := len(w.cells); n > 0 {
+			if w.cells[n-1] > 0 {
+				w.write(" | ")
+			}
+			w.cells[n-1]++
+		}
+
+	case atom.A:
+		w.links = append(w.links, link{href: attrs["href"], start: w.buf.Len()})
+	}
+}
+
+func (w *textWriter) end(tag atom.Atom) {
+	switch tag {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template, atom.Noscript:
+		if w.skip > 0 {
+			w.skip--
+		}
+
+	case atom.Pre:
+		if w.pre > 0 {
+			w.pre--
+		}
+		w.block(2)
+
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Table:
+		w.block(2)
+
+	case atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Li:
+		w.block(1)
+
+	case atom.Ul, atom.Ol:
+		if n := len(w.lists); n > 0 {
+			w.lists = w.lists[:n-1]
+		}
+		w.block(1)
+
+	case atom.Tr:
+		if n := len(w.cells); n > 0 {
+			w.cells = w.cells[:n-1]
+		}
+		w.block(1)
+
+	case atom.A:
+		n := len(w.links)
+		if n == 0 {
+			return
+		}
+
+		link := w.links[n-1]
+		w.links = w.links[:n-1]
+		if w.skip > 0 || !showLink(link.href) {
+			return
+		}
+
+		text := strings.TrimSpace(w.buf.String()[link.start:])
+		switch text {
+		case "":
+			w.text(link.href)
+		case link.href, strings.TrimPrefix(link.href, "mailto:"):
+		default:
+			w.text(" (" + link.href + ")")
+		}
+	}
+}
+
+func showLink(href string) bool {
+	switch {
+	case href == "", strings.HasPrefix(href, "#"):
+		return false
+	case strings.HasPrefix(href, "cid:"), strings.HasPrefix(href, "javascript:"):
+		return false
+	default:
+		return true
+	}
+}
@@ -24,11 +24,15 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/xgfone/go-msgnotice/tools/retryafter"
 	"github.com/xgfone/go-toolkit/jsonx"
 	"github.com/xgfone/go-toolkit/unsafex"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const webhookBaseURL = "https://open.feishu.cn/open-apis/bot/v2/hook/"
@@ -176,6 +180,7 @@ func (w Webhook) send(ctx context.Context, msgtype string, content any) (err err
 		return fmt.Errorf("fail to create a new request: %w", err)
 	}
 	httpreq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpreq.Header))
 
 	httpresp, err := w.do(httpreq)
 	if err != nil {
@@ -190,16 +195,68 @@ func (w Webhook) send(ctx context.Context, msgtype string, content any) (err err
 
 	var resp Response
 	if err = jsonx.UnmarshalReader(&resp, bytes.NewReader(data)); err != nil {
+		if httpresp.StatusCode >= 400 {
+			return newError(httpresp, -1, strings.TrimSpace(string(data)))
+		}
 		return fmt.Errorf("fail to decode the response body by json: data=%s, err=%w", unsafex.String(data), err)
 	}
 
-	if resp.Code != 0 {
-		return fmt.Errorf("%d: %s", resp.Code, resp.Msg)
+	if resp.Code != 0 || httpresp.StatusCode >= 400 {
+		return newError(httpresp, resp.Code, resp.Msg)
 	}
 
 	return
 }
 
+// The error codes of the feishu webhook for the rate limit.
+const (
+	CodeTooManyRequests = 9499
+	CodeRateLimited     = 11232
+)
+
+// Error is the error returned by the feishu webhook.
+type Error struct {
+	StatusCode int    // The http status code.
+	Code       int    // The feishu error code, which is -1 if the response is not json.
+	Msg        string // The feishu error message or the response body.
+
+	retryAfter time.Duration
+}
+
+func newError(resp *http.Response, code int, msg string) Error {
+	return Error{
+		StatusCode: resp.StatusCode,
+		Code:       code,
+		Msg:        msg,
+		retryAfter: retryafter.Parse(resp.Header.Get("Retry-After")),
+	}
+}
+
+// Error implements the interface error.
+func (e Error) Error() string {
+	if e.Code < 0 {
+		return fmt.Sprintf("status=%d: %s", e.StatusCode, e.Msg)
+	}
+	return fmt.Sprintf("%d: %s", e.Code, e.Msg)
+}
+
+// Temporary reports whether the error is temporary, that's,
+// it is rate-limited, or the http status code is 5xx.
+func (e Error) Temporary() bool {
+	switch {
+	case e.Code == CodeTooManyRequests, e.Code == CodeRateLimited:
+		return true
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryAfter returns the delay to retry, which is parsed from
+// the response header Retry-After. Return 0 if not set.
+func (e Error) RetryAfter() time.Duration { return e.retryAfter }
+
 func (w Webhook) getsign() (sign, timestamp string) {
 	if w.key == "" {
 		return
@@ -0,0 +1,42 @@
+// Copyright 2025 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retryafter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for value, expect := range map[string]time.Duration{
+		"":    0,
+		"abc": 0,
+		"-1":  0,
+		"0":   0,
+		"120": 2 * time.Minute,
+
+		time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat): 0,
+	} {
+		if delay := Parse(value); delay != expect {
+			t.Errorf("%q: expect %s, but got %s", value, expect, delay)
+		}
+	}
+
+	value := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if delay := Parse(value); delay <= 59*time.Minute || delay > time.Hour {
+		t.Errorf("%q: expect about 1h, but got %s", value, delay)
+	}
+}